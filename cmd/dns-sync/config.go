@@ -0,0 +1,45 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gopkg.in/yaml.v3"
+)
+
+// runConfig runs the config subcommands
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "show" {
+		return fmt.Errorf("usage: dns-sync config show [-config config.yaml] [-format yaml|json]")
+	}
+	return runConfigShow(args[1:])
+}
+
+// runConfigShow prints the effective configuration, with defaults applied and secrets redacted
+func runConfigShow(args []string) error {
+	flags, common := newFlagSet("config show")
+	format := flags.String("format", "yaml", "Output format (yaml, json)")
+	_ = flags.Parse(args)
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	redacted := cfg.Redacted()
+
+	switch *format {
+	case "yaml":
+		encoder := yaml.NewEncoder(os.Stdout)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(redacted); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case "json":
+		encoder := json.NewEncoder(os.Stdout)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(redacted)
+	}
+	return fmt.Errorf("unknown format %q, expected yaml or json", *format)
+}
@@ -0,0 +1,189 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+
+	"github.com/flanksource/dns-sync/sync"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// exitCode is returned by commands that exit with a specific code, having already reported why
+type exitCode int
+
+func (c exitCode) Error() string {
+	return fmt.Sprintf("exit code %d", int(c))
+}
+
+// runDiff compares the records of a zone held by two providers without changing them. It exits with 0 when
+// they hold the same records, 1 when they differ and 2 on errors, so that it can be used for drift checks.
+func runDiff(args []string) error {
+	differs, err := diff(args)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return exitCode(2)
+	}
+	if differs {
+		return exitCode(1)
+	}
+	return nil
+}
+
+func diff(args []string) (bool, error) {
+	flags, common := newFlagSet("diff")
+	zone := flags.String("zone", "", "Zone to compare (required)")
+	left := flags.String("left", "source", "Provider to compare: source, target[N] for the target at index N, or a provider configuration as YAML, e.g. \"file: {path: zone.db}\"")
+	right := flags.String("right", "target[0]", "Provider to compare the left one to, given as for -left")
+	format := flags.String("format", "table", "Output format (table, json, unified)")
+	_ = flags.Parse(args)
+
+	if *zone == "" {
+		return false, fmt.Errorf("usage: dns-sync diff -zone zone [-left source] [-right target[0]] [-format table|json|unified]")
+	}
+	if *format != "table" && *format != "json" && *format != "unified" {
+		return false, fmt.Errorf("unknown format %q, expected table, json or unified", *format)
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return false, err
+	}
+	zoneDiff, err := sync.NewSynchronizer(*cfg).Diff(context.Background(), *zone, *left, *right)
+	if err != nil {
+		return false, err
+	}
+
+	rows := diffRows(zoneDiff)
+	switch *format {
+	case "json":
+		err = printDiffJSON(os.Stdout, zoneDiff, rows)
+	case "unified":
+		printDiffUnified(os.Stdout, zoneDiff, rows)
+	default:
+		printDiffTable(os.Stdout, zoneDiff, rows)
+	}
+	return zoneDiff.Differs(), err
+}
+
+// diffRow holds the values of a record set that differ between the providers
+type diffRow struct {
+	Name  string       `json:"name"`
+	Type  string       `json:"type"`
+	Left  []diffRecord `json:"left"`
+	Right []diffRecord `json:"right"`
+}
+
+// diffRecord is a value of a record set held by a single provider
+type diffRecord struct {
+	TTL    endpoint.TTL `json:"ttl"`
+	Target string       `json:"target"`
+}
+
+// diffRows groups the differences by record name and type
+func diffRows(zoneDiff *sync.ZoneDiff) []*diffRow {
+	rows := make(map[[2]string]*diffRow)
+	row := func(record *endpoint.Endpoint) *diffRow {
+		key := [2]string{record.DNSName, record.RecordType}
+		if rows[key] == nil {
+			rows[key] = &diffRow{Name: record.DNSName, Type: record.RecordType, Left: []diffRecord{}, Right: []diffRecord{}}
+		}
+		return rows[key]
+	}
+	for _, record := range zoneDiff.Changes.Create {
+		r := row(record)
+		for _, target := range record.Targets {
+			r.Left = append(r.Left, diffRecord{TTL: record.RecordTTL, Target: target})
+		}
+	}
+	for _, record := range zoneDiff.Changes.Delete {
+		r := row(record)
+		for _, target := range record.Targets {
+			r.Right = append(r.Right, diffRecord{TTL: record.RecordTTL, Target: target})
+		}
+	}
+
+	result := make([]*diffRow, 0, len(rows))
+	for _, r := range rows {
+		sortDiffRecords(r.Left)
+		sortDiffRecords(r.Right)
+		result = append(result, r)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Name != result[j].Name {
+			return result[i].Name < result[j].Name
+		}
+		return result[i].Type < result[j].Type
+	})
+	return result
+}
+
+func sortDiffRecords(records []diffRecord) {
+	sort.Slice(records, func(i, j int) bool {
+		if records[i].Target != records[j].Target {
+			return records[i].Target < records[j].Target
+		}
+		return records[i].TTL < records[j].TTL
+	})
+}
+
+// printDiffTable writes the differences side by side, one value of each provider per line
+func printDiffTable(w io.Writer, zoneDiff *sync.ZoneDiff, rows []*diffRow) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "NAME\tTYPE\tLEFT %s\tRIGHT %s\n", zoneDiff.Left.Provider, zoneDiff.Right.Provider)
+	for _, row := range rows {
+		for i := 0; i < max(len(row.Left), len(row.Right)); i++ {
+			var left, right string
+			if i < len(row.Left) {
+				left = fmt.Sprintf("%d %s", row.Left[i].TTL, row.Left[i].Target)
+			}
+			if i < len(row.Right) {
+				right = fmt.Sprintf("%d %s", row.Right[i].TTL, row.Right[i].Target)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", row.Name, row.Type, left, right)
+		}
+	}
+	_ = tw.Flush()
+
+	if len(rows) == 0 {
+		fmt.Fprintf(w, "\nZone %s: no differences\n", zoneDiff.Zone)
+		return
+	}
+	fmt.Fprintf(w, "\nZone %s: %d record sets differ, %d records only in %s and %d only in %s\n", zoneDiff.Zone, len(rows),
+		len(zoneDiff.Changes.Create), zoneDiff.Left.Provider, len(zoneDiff.Changes.Delete), zoneDiff.Right.Provider)
+}
+
+// printDiffJSON writes the differences as a JSON document
+func printDiffJSON(w io.Writer, zoneDiff *sync.ZoneDiff, rows []*diffRow) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(struct {
+		Zone    string     `json:"zone"`
+		Left    string     `json:"left"`
+		Right   string     `json:"right"`
+		Differs bool       `json:"differs"`
+		Records []*diffRow `json:"records"`
+	}{zoneDiff.Zone, zoneDiff.Left.Provider, zoneDiff.Right.Provider, zoneDiff.Differs(), rows})
+}
+
+// printDiffUnified writes the differences as a unified diff of zone file lines, from the left provider
+// to the right one
+func printDiffUnified(w io.Writer, zoneDiff *sync.ZoneDiff, rows []*diffRow) {
+	if len(rows) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "--- %s\n+++ %s\n", zoneDiff.Left.Provider, zoneDiff.Right.Provider)
+	for _, row := range rows {
+		fmt.Fprintf(w, "@@ %s %s @@\n", row.Name, row.Type)
+		for _, record := range row.Left {
+			fmt.Fprintf(w, "-%s.\t%d\tIN\t%s\t%s\n", row.Name, record.TTL, row.Type, record.Target)
+		}
+		for _, record := range row.Right {
+			fmt.Fprintf(w, "+%s.\t%d\tIN\t%s\t%s\n", row.Name, record.TTL, row.Type, record.Target)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/flanksource/dns-sync/config/providers"
+	"github.com/flanksource/dns-sync/sync"
+)
+
+// exportSOANote documents the SOA record generated by WriteZone for zones exported without one
+const exportSOANote = `Most providers do not list the SOA record of a zone. When none is exported, one is generated so that
+the file can be loaded by a name server, to be reviewed before use: the primary name server is the first
+NS record of the zone apex or else the zone itself, the mailbox is hostmaster.<zone>, the serial is the
+export time in UTC as YYYYMMDDHH, and the refresh, retry, expire and minimum TTL are 3600, 600, 86400
+and 300 seconds.
+`
+
+// runExport writes the records of a zone, as held by its source or one of its targets, to a BIND zone file
+func runExport(args []string) error {
+	flags, common := newFlagSet("export")
+	zone := flags.String("zone", "", "Zone to export (required)")
+	from := flags.String("from", "source", "Provider to export the zone from: source or target[N] for the target at index N")
+	out := flags.String("o", "", "Write the zone file to this path instead of stdout")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: dns-sync export -zone zone [-from source|target[N]] [-o zone.db]\n\n%s\nFlags:\n", exportSOANote)
+		flags.PrintDefaults()
+	}
+	_ = flags.Parse(args)
+
+	if *zone == "" {
+		return fmt.Errorf("usage: dns-sync export -zone zone [-from source|target[N]] [-o zone.db]")
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	records, err := sync.NewSynchronizer(*cfg).Records(context.Background(), *zone, *from)
+	if err != nil {
+		return err
+	}
+
+	if *out == "" {
+		return writeExport(os.Stdout, records)
+	}
+
+	file, err := os.Create(*out)
+	if err != nil {
+		return fmt.Errorf("failed to create zone file: %w", err)
+	}
+	if err := writeExport(file, records); err != nil {
+		_ = file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write zone file: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "Exported %d records of zone %s from %s to %s\n", len(records.Records), records.Zone, records.Provider, *out)
+	return nil
+}
+
+// writeExport writes the records of a zone as a BIND zone file, preceded by a comment naming their provider
+func writeExport(w io.Writer, records *sync.ZoneRecords) error {
+	writer := bufio.NewWriter(w)
+	fmt.Fprintf(writer, "; Zone %s exported from %s by dns-sync at %s\n", records.Zone, records.Provider, time.Now().Format(time.RFC3339))
+	if err := providers.WriteZone(writer, records.Zone, records.Records); err != nil {
+		return err
+	}
+	return writer.Flush()
+}
@@ -0,0 +1,53 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/flanksource/dns-sync/config"
+)
+
+// commonFlags are the flags shared by all subcommands: the configuration file and the logging options
+type commonFlags struct {
+	configFile string
+	logging    logOptions
+}
+
+// newFlagSet returns the flag set of a subcommand with the common flags registered
+func newFlagSet(name string) (*flag.FlagSet, *commonFlags) {
+	common := &commonFlags{}
+	flags := flag.NewFlagSet(name, flag.ExitOnError)
+	flags.StringVar(&common.configFile, "config", "config.yaml", "Configuration file path")
+	flags.StringVar(&common.logging.level, "log-level", "", "Log level (debug, info, warn, error), overrides log_level of the configuration")
+	flags.StringVar(&common.logging.format, "log-format", "", "Log format (text, json), overrides log_format of the configuration")
+	flags.Usage = func() {
+		fmt.Fprintf(flags.Output(), "Usage: dns-sync %s [flags]\n\nFlags:\n", name)
+		flags.PrintDefaults()
+	}
+	return flags, common
+}
+
+// loadConfig loads the configuration file and sets up logging from it and the logging flags
+func (c *commonFlags) loadConfig() (*config.Config, error) {
+	cfg, err := config.Load(c.configFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := c.logging.setup(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// selectZone restricts the configuration to a single zone, leaving it untouched when zone is empty
+func selectZone(cfg *config.Config, zone string) error {
+	if zone == "" {
+		return nil
+	}
+	zoneConfig := cfg.Zone(zone)
+	if zoneConfig == nil {
+		return fmt.Errorf("zone %s is not configured", zone)
+	}
+	cfg.Zones = []*config.ZoneConfig{zoneConfig}
+	return nil
+}
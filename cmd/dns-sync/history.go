@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/flanksource/dns-sync/journal"
+)
+
+// runHistory prints the journal entries matching the zone, record name and time range given as flags
+func runHistory(args []string) error {
+	flags, common := newFlagSet("history")
+	journalPath := flags.String("journal", "", "Journal file path, overrides the path from the configuration")
+	zone := flags.String("zone", "", "Only show changes to this zone")
+	name := flags.String("name", "", "Only show changes to records with this name")
+	since := flags.String("since", "", "Only show changes after this time (RFC3339, YYYY-MM-DD or a duration such as 24h)")
+	until := flags.String("until", "", "Only show changes before this time (RFC3339, YYYY-MM-DD or a duration such as 24h)")
+	asJSON := flags.Bool("json", false, "Print entries as JSON lines")
+	_ = flags.Parse(args)
+
+	path := *journalPath
+	if path == "" {
+		cfg, err := common.loadConfig()
+		if err != nil {
+			return err
+		}
+		if path = cfg.Journal.Path; path == "" {
+			return fmt.Errorf("the journal is not enabled in %s", common.configFile)
+		}
+	}
+
+	query := journal.Query{Zone: *zone, Name: *name}
+	var err error
+	if query.Since, err = parseTime(*since); err != nil {
+		return fmt.Errorf("invalid -since: %w", err)
+	}
+	if query.Until, err = parseTime(*until); err != nil {
+		return fmt.Errorf("invalid -until: %w", err)
+	}
+
+	entries, err := journal.Read(path, query)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	for _, entry := range entries {
+		if *asJSON {
+			if err := encoder.Encode(entry); err != nil {
+				return err
+			}
+			continue
+		}
+		fmt.Printf("%s zone %s, target %s (config %s)\n", entry.Time.Format(time.RFC3339), entry.Zone, entry.Target, entry.ConfigHash)
+		printChanges(os.Stdout, entry.Changes)
+	}
+	return nil
+}
+
+// parseTime parses an absolute time, or a duration relative to now
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		return t, nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("%q is not a time or duration", value)
+	}
+	return time.Now().Add(-d), nil
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/flanksource/dns-sync/config"
+	log "github.com/sirupsen/logrus"
+)
+
+// logOptions are the -log-level and -log-format flags, which override log_level and log_format of the
+// configuration file when set
+type logOptions struct {
+	level  string
+	format string
+}
+
+// setup configures the logger from the flags and the configuration
+func (o logOptions) setup(cfg *config.Config) error {
+	level, format := o.level, o.format
+	if level == "" {
+		level = cfg.LogLevel
+	}
+	if format == "" {
+		format = cfg.LogFormat
+	}
+	return setupLogging(level, format)
+}
+
+// setupLogging configures the level and format of the logger, which writes to stderr so that the output
+// of subcommands can be piped
+func setupLogging(level, format string) error {
+	if level == "" {
+		level = "info"
+	}
+	lvl, err := log.ParseLevel(level)
+	if err != nil {
+		return fmt.Errorf("invalid log level %q", level)
+	}
+
+	switch format {
+	case "", "text":
+		log.SetFormatter(&log.TextFormatter{FullTimestamp: true})
+	case "json":
+		log.SetFormatter(&log.JSONFormatter{})
+	default:
+		return fmt.Errorf("invalid log format %q, expected text or json", format)
+	}
+	log.SetLevel(lvl)
+	log.SetOutput(os.Stderr)
+	return nil
+}
@@ -1,16 +1,13 @@
 package main
 
 import (
-	"context"
-	"flag"
+	"errors"
 	"fmt"
-	"log"
 	"os"
-	"os/signal"
-	"syscall"
+	"slices"
+	"strings"
 
-	"github.com/flanksource/dns-sync/config"
-	"github.com/flanksource/dns-sync/sync"
+	log "github.com/sirupsen/logrus"
 )
 
 var (
@@ -19,76 +16,99 @@ var (
 	date    = "unknown"
 )
 
-func main() {
-	var configFile = flag.String("config", "config.yaml", "Configuration file path")
-	var logLevel = flag.String("log-level", "info", "Log level (debug, info, warn, error)")
-	var showVersion = flag.Bool("version", false, "Show version information")
-	var dryRun = flag.Bool("dry-run", false, "Enable dry run mode (no changes made)")
-	var once = flag.Bool("once", false, "Run synchronization once and exit")
-	flag.Parse()
-
-	if *showVersion {
-		fmt.Printf("dns-sync version %s (commit: %s, built: %s)\n", version, commit, date)
-		os.Exit(0)
-	}
+// commands are the subcommands of dns-sync, invoked as dns-sync <command> [flags] [args]
+var commands = map[string]func(args []string) error{
+	"run":      runRun,
+	"sync":     runSync,
+	"plan":     runPlan,
+	"apply":    runApply,
+	"diff":     runDiff,
+	"export":   runExport,
+	"status":   runStatus,
+	"history":  runHistory,
+	"rollback": runRollback,
+	"validate": runValidate,
+	"config":   runConfig,
+}
 
-	// Load configuration
-	cfg, err := config.Load(*configFile)
-	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
-	}
-	if dryRun != nil {
-		cfg.Sync.DryRun = *dryRun
-	}
+const usage = `Usage: dns-sync <command> [flags]
 
-	// Setup logging
-	setupLogging(*logLevel)
+Commands:
+  run       Synchronize zones continuously, serving health checks, status and metrics
+            dns-sync run [-dry-run] [-reload-interval 10s]
+  sync      Synchronize zones once and exit
+            dns-sync sync [-zone zone] [-dry-run]
+  plan      Show the changes a sync would make, optionally saving them to a plan file
+            dns-sync plan [-zone zone] [-out plan.json]
+  apply     Apply the changes of a plan file
+            dns-sync apply <plan file>
+  diff      Compare the records of a zone held by two providers, exiting with 1 when they differ
+            dns-sync diff -zone zone [-left source] [-right target[0]] [-format table|json|unified]
+  export    Write the records of a zone, as held by its source or a target, to a BIND zone file,
+            generating a SOA record when none is exported (see dns-sync export -h)
+            dns-sync export -zone zone [-from source|target[N]] [-o zone.db]
+  status    Show the sync status of a running instance
+            dns-sync status [-address host:port] [-json]
+  history   Show the changes applied to the targets
+            dns-sync history [-zone zone] [-name name] [-since time] [-until time] [-json]
+  rollback  Restore a target to a snapshot, listing the snapshots when none is given
+            dns-sync rollback -zone zone -target target [-to snapshot] [-dry-run]
+  validate  Check the configuration without connecting to any provider
+  config    Print the effective configuration with secrets redacted
+            dns-sync config show [-format yaml|json]
+  version   Show version information
 
-	// Initialize synchronizer
-	syncer := sync.NewSynchronizer(*cfg)
+All commands accept -config (default config.yaml), -log-level and -log-format, run
+dns-sync <command> -h for the flags of a command. Without a command, or with flags
+only, dns-sync runs as dns-sync run.
+`
 
-	// Start the synchronizer
-	log.Println("Starting DNS synchronizer...")
-	if once != nil && *once {
-		if err, _ := syncer.Once(context.Background()); err != nil {
-			log.Fatalf("Synchronizer failed: %v", err)
-		}
-	} else {
-		// Setup graceful shutdown
-		ctx, cancel := context.WithCancel(context.Background())
-		defer cancel()
+func main() {
+	args := os.Args[1:]
+	name := "run"
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		name, args = args[0], args[1:]
+	} else if slices.ContainsFunc(args, isHelpFlag) {
+		// Without a command the help flags show the commands rather than the flags of run
+		name = "help"
+	}
 
-		// Handle OS signals
-		sigCh := make(chan os.Signal, 1)
-		signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	switch name {
+	case "help":
+		fmt.Print(usage)
+		return
+	case "version":
+		printVersion()
+		return
+	}
 
-		go func() {
-			sig := <-sigCh
-			log.Printf("Received signal %v, shutting down gracefully...", sig)
-			cancel()
-		}()
-		if err := syncer.Start(ctx); err != nil {
-			log.Fatalf("Synchronizer failed: %v", err)
+	command, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n%s", name, usage)
+		os.Exit(2)
+	}
+	if err := command(args); err != nil {
+		var code exitCode
+		if errors.As(err, &code) {
+			os.Exit(int(code))
+		}
+		if name == "run" {
+			log.WithError(err).Fatal("DNS synchronizer failed")
 		}
-		log.Println("DNS synchronizer stopped")
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(1)
 	}
-
 }
 
-func setupLogging(level string) {
-	// Configure logging based on level
-	log.SetFlags(log.LstdFlags | log.Lshortfile)
-
-	switch level {
-	case "debug":
-		// Enable debug logging
-	case "info":
-		// Default info logging
-	case "warn":
-		// Warning and above
-	case "error":
-		// Error only
-	default:
-		log.Printf("Unknown log level: %s, using info", level)
+// isHelpFlag returns true for the flags that show the usage of a flag set
+func isHelpFlag(arg string) bool {
+	switch strings.TrimPrefix(arg, "-") {
+	case "-h", "-help", "h", "help":
+		return true
 	}
+	return false
+}
+
+func printVersion() {
+	fmt.Printf("dns-sync version %s (commit: %s, built: %s)\n", version, commit, date)
 }
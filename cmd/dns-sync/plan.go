@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/flanksource/dns-sync/sync"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// runPlan calculates the changes for all zones and targets, printing them and optionally saving them
+// to a plan file for dns-sync apply
+func runPlan(args []string) error {
+	flags, common := newFlagSet("plan")
+	zone := flags.String("zone", "", "Only plan the changes of this zone")
+	out := flags.String("out", "", "Save the plan to this file, to be applied with dns-sync apply")
+	_ = flags.Parse(args)
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := selectZone(cfg, *zone); err != nil {
+		return err
+	}
+
+	planFile, err := sync.NewSynchronizer(*cfg).Plan(context.Background())
+	if err != nil {
+		return err
+	}
+	printPlan(os.Stdout, planFile)
+
+	if *out != "" {
+		if err := sync.WritePlanFile(*out, planFile); err != nil {
+			return err
+		}
+		fmt.Printf("\nPlan saved to %s, apply it with: dns-sync apply %s\n", *out, *out)
+	}
+	return nil
+}
+
+// runApply applies the changes of a plan file created by dns-sync plan -out
+func runApply(args []string) error {
+	flags, common := newFlagSet("apply")
+	_ = flags.Parse(args)
+
+	if flags.NArg() != 1 {
+		return fmt.Errorf("usage: dns-sync apply [-config config.yaml] <plan file>")
+	}
+
+	planFile, err := sync.ReadPlanFile(flags.Arg(0))
+	if err != nil {
+		return err
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	return sync.NewSynchronizer(*cfg).Apply(context.Background(), planFile)
+}
+
+// printPlan writes the changes of a plan in a human readable form
+func printPlan(w io.Writer, planFile *sync.PlanFile) {
+	var creates, updates, deletes int
+	for _, zone := range planFile.Zones {
+		for _, target := range zone.Targets {
+			changes := target.Changes
+			fmt.Fprintf(w, "Zone %s, target %s: %d to create, %d to update, %d to delete\n", zone.Zone, target.Provider,
+				len(changes.Create), len(changes.UpdateNew), len(changes.Delete))
+			printChanges(w, changes)
+			creates += len(changes.Create)
+			updates += len(changes.UpdateNew)
+			deletes += len(changes.Delete)
+		}
+	}
+	fmt.Fprintf(w, "\nPlan: %d to create, %d to update, %d to delete\n", creates, updates, deletes)
+}
+
+// printChanges writes one line per changed record, prefixed with + for creates, ~ for updates and - for deletes
+func printChanges(w io.Writer, changes *plan.Changes) {
+	for _, record := range changes.Create {
+		fmt.Fprintf(w, "  + %s\n", record)
+	}
+	for i, record := range changes.UpdateNew {
+		if i < len(changes.UpdateOld) {
+			fmt.Fprintf(w, "  ~ %s\n    -> %s\n", changes.UpdateOld[i], record)
+		} else {
+			fmt.Fprintf(w, "  ~ %s\n", record)
+		}
+	}
+	for _, record := range changes.Delete {
+		fmt.Fprintf(w, "  - %s\n", record)
+	}
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/flanksource/dns-sync/sync"
+	log "github.com/sirupsen/logrus"
+)
+
+// watchConfig reloads the configuration file into the synchronizer on SIGHUP, and when the modification
+// time of the file changes, checked every interval (0 disables polling). A configuration that fails to
+// load or validate is logged and the current one is kept. dryRun forces dry run mode on reloaded
+// configurations, as the -dry-run flag does for the initial one.
+func watchConfig(ctx context.Context, path string, interval time.Duration, dryRun bool, logging logOptions, syncer *sync.Synchronizer) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+
+	var poll <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		poll = ticker.C
+	}
+
+	modTime := func() time.Time {
+		info, err := os.Stat(path)
+		if err != nil {
+			return time.Time{}
+		}
+		return info.ModTime()
+	}
+	lastModified := modTime()
+
+	reload := func(reason string) {
+		logger := log.WithFields(log.Fields{"path": path, "reason": reason})
+		logger.Info("Reloading configuration")
+		cfg, err := config.Load(path)
+		if err != nil {
+			logger.WithError(err).Error("Failed to reload configuration, keeping the current one")
+			return
+		}
+		if dryRun {
+			cfg.Sync.DryRun = true
+		}
+		if err := syncer.Reload(*cfg); err != nil {
+			logger.WithError(err).Error("Failed to reload configuration, keeping the current one")
+			return
+		}
+		if err := logging.setup(cfg); err != nil {
+			logger.WithError(err).Error("Failed to setup logging")
+		}
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			lastModified = modTime()
+			reload("SIGHUP")
+		case <-poll:
+			// A missing file is usually being replaced, wait for it to reappear
+			if modified := modTime(); !modified.IsZero() && !modified.Equal(lastModified) {
+				lastModified = modified
+				reload("file changed")
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/flanksource/dns-sync/sync"
+)
+
+// runRollback restores a target of a zone to a snapshot, or lists the snapshots of the target when no
+// snapshot is given
+func runRollback(args []string) error {
+	flags, common := newFlagSet("rollback")
+	zone := flags.String("zone", "", "Zone to roll back (required)")
+	target := flags.String("target", "", "Index or provider name of the target to roll back (required)")
+	to := flags.String("to", "", "Id of the snapshot to restore, the snapshots are listed when empty")
+	dryRun := flags.Bool("dry-run", false, "Show the changes without applying them")
+	_ = flags.Parse(args)
+
+	if *zone == "" || *target == "" {
+		return fmt.Errorf("usage: dns-sync rollback [-config config.yaml] -zone zone -target target [-to snapshot] [-dry-run]")
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		cfg.Sync.DryRun = true
+	}
+
+	syncer := sync.NewSynchronizer(*cfg)
+	index, err := syncer.TargetIndex(*zone, *target)
+	if err != nil {
+		return err
+	}
+
+	if *to == "" {
+		snapshots, err := syncer.Snapshots(*zone, index)
+		if err != nil {
+			return err
+		}
+		for _, snapshot := range snapshots {
+			fmt.Printf("%s  %s  %d records\n", snapshot.ID, snapshot.Time.Local().Format(time.RFC3339), len(snapshot.Records))
+		}
+		return nil
+	}
+
+	changes, err := syncer.Rollback(context.Background(), *zone, index, *to)
+	if err != nil {
+		return err
+	}
+	printChanges(os.Stdout, changes)
+	fmt.Printf("\nRollback: %d to create, %d to update, %d to delete\n", len(changes.Create), len(changes.UpdateNew), len(changes.Delete))
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/flanksource/dns-sync/server"
+	"github.com/flanksource/dns-sync/sync"
+	log "github.com/sirupsen/logrus"
+)
+
+// runRun runs the synchronizer until it receives SIGINT or SIGTERM, serving health checks, status and
+// metrics. It also accepts the flags of the legacy flag-only command line.
+func runRun(args []string) error {
+	flags, common := newFlagSet("run")
+	dryRun := flags.Bool("dry-run", false, "Enable dry run mode (no changes made)")
+	once := flags.Bool("once", false, "Run synchronization once and exit, prefer dns-sync sync")
+	healthcheck := flags.Bool("healthcheck", false, "Check the health endpoint of a running instance and exit")
+	showVersion := flags.Bool("version", false, "Show version information")
+	reloadInterval := flags.Duration("reload-interval", 10*time.Second, "How often to check the configuration file for changes (0 = only reload on SIGHUP)")
+	_ = flags.Parse(args)
+
+	if *showVersion {
+		printVersion()
+		return nil
+	}
+
+	if *healthcheck {
+		if err := checkHealth(common.configFile); err != nil {
+			return fmt.Errorf("health check failed: %w", err)
+		}
+		return nil
+	}
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	if *dryRun {
+		cfg.Sync.DryRun = true
+	}
+
+	syncer := sync.NewSynchronizer(*cfg)
+
+	log.WithFields(log.Fields{"version": version, "zones": len(cfg.Zones), "dry_run": cfg.Sync.DryRun}).Info("Starting DNS synchronizer")
+	if *once {
+		_, err := syncer.Once(context.Background())
+		return err
+	}
+
+	// Bind the HTTP server before starting, so that an address in use stops the process
+	httpServer := server.New(cfg.MetricsAddress, syncer)
+	if err := httpServer.Listen(); err != nil {
+		return fmt.Errorf("failed to start HTTP server: %w", err)
+	}
+
+	// Setup graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Handle OS signals
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		sig := <-sigCh
+		log.WithField("signal", sig.String()).Info("Shutting down gracefully")
+		cancel()
+	}()
+
+	go watchConfig(ctx, common.configFile, *reloadInterval, *dryRun, common.logging, syncer)
+
+	go func() {
+		if err := httpServer.Start(ctx); err != nil {
+			log.WithError(err).Error("HTTP server failed")
+		}
+	}()
+
+	if err := syncer.Start(ctx); err != nil && ctx.Err() == nil {
+		return fmt.Errorf("synchronizer failed: %w", err)
+	}
+	log.Info("DNS synchronizer stopped")
+	return nil
+}
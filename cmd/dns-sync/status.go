@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/flanksource/dns-sync/sync"
+)
+
+// runStatus prints the sync status of the zones of a running instance, queried from its HTTP API
+func runStatus(args []string) error {
+	flags, common := newFlagSet("status")
+	address := flags.String("address", "", "Address of the running instance, defaults to metrics_address of the configuration")
+	asJSON := flags.Bool("json", false, "Print the status as JSON")
+	_ = flags.Parse(args)
+
+	addr := *address
+	if addr == "" {
+		addr = metricsAddress(common.configFile)
+	}
+	resp, err := get(addr, "/status")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if *asJSON {
+		_, err := io.Copy(os.Stdout, resp.Body)
+		return err
+	}
+
+	var zones []sync.ZoneStatus
+	if err := json.NewDecoder(resp.Body).Decode(&zones); err != nil {
+		return fmt.Errorf("failed to decode status: %w", err)
+	}
+	printStatus(os.Stdout, zones)
+	return nil
+}
+
+// printStatus writes the status of every zone and its targets as a table
+func printStatus(w io.Writer, zones []sync.ZoneStatus) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ZONE\tTARGET\tLAST SYNC\tLAST SUCCESS\tRECORDS\tCHANGES\tERROR")
+	for _, zone := range zones {
+		fmt.Fprintf(tw, "%s\t\t%s\t%s\t%d\t\t%s\n", zone.Name, formatTime(zone.LastSync), formatTime(zone.LastSuccess), zone.RecordCount, zone.LastError)
+		for _, target := range zone.TargetStatus {
+			lastError := target.LastError
+			if target.Circuit != "" && target.Circuit != "closed" {
+				lastError = fmt.Sprintf("circuit %s: %s", target.Circuit, lastError)
+			}
+			fmt.Fprintf(tw, "\t%s\t%s\t%s\t%d\t+%d ~%d -%d\t%s\n", target.Provider, formatTime(target.LastSync), formatTime(target.LastSuccess),
+				target.RecordCount, target.Creates, target.Updates, target.Deletes, lastError)
+		}
+	}
+	_ = tw.Flush()
+}
+
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return "never"
+	}
+	return t.Local().Format(time.RFC3339)
+}
+
+// checkHealth queries the /health endpoint of the instance configured in configFile
+func checkHealth(configFile string) error {
+	resp, err := get(metricsAddress(configFile), "/health")
+	if err != nil {
+		return err
+	}
+	return resp.Body.Close()
+}
+
+// metricsAddress returns the address a running instance serves its HTTP API on, as configured in
+// configFile, falling back to the default address when it can't be loaded
+func metricsAddress(configFile string) string {
+	if cfg, err := config.Load(configFile); err == nil {
+		return cfg.MetricsAddress
+	}
+	return ":7979"
+}
+
+// get requests path from the HTTP API of the instance listening on addr, which is queried on localhost
+// when it doesn't specify a host. Responses other than 200 OK are returned as errors.
+func get(addr, path string) (*http.Response, error) {
+	host, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	client := http.Client{Timeout: 5 * time.Second}
+	resp, err := client.Get(fmt.Sprintf("http://%s%s", net.JoinHostPort(host, port), path))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return resp, nil
+}
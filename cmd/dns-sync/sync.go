@@ -0,0 +1,38 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/flanksource/dns-sync/sync"
+)
+
+// runSync synchronizes all zones, or a single one, once and exits
+func runSync(args []string) error {
+	flags, common := newFlagSet("sync")
+	zone := flags.String("zone", "", "Only synchronize this zone")
+	dryRun := flags.Bool("dry-run", false, "Log the changes without applying them")
+	_ = flags.Parse(args)
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := selectZone(cfg, *zone); err != nil {
+		return err
+	}
+	if *dryRun {
+		cfg.Sync.DryRun = true
+	}
+
+	changes, err := sync.NewSynchronizer(*cfg).Once(context.Background())
+	for _, zoneConfig := range cfg.Zones {
+		for _, targetConfig := range zoneConfig.Targets {
+			if chg := changes[zoneConfig.Name][targetConfig]; chg != nil {
+				fmt.Printf("Zone %s, target %s: %d created, %d updated, %d deleted\n", zoneConfig.Name, targetConfig.ProviderConfig.String(),
+					len(chg.Create), len(chg.UpdateNew), len(chg.Delete))
+			}
+		}
+	}
+	return err
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/flanksource/dns-sync/sync"
+)
+
+// runValidate checks a configuration file without connecting to any provider, failing when it is invalid
+func runValidate(args []string) error {
+	flags, common := newFlagSet("validate")
+	_ = flags.Parse(args)
+
+	cfg, err := common.loadConfig()
+	if err != nil {
+		return err
+	}
+	if err := sync.Validate(*cfg); err != nil {
+		return fmt.Errorf("invalid configuration in %s: %w", common.configFile, err)
+	}
+
+	targets := 0
+	for _, zone := range cfg.Zones {
+		targets += len(zone.Targets)
+	}
+	fmt.Printf("Configuration %s is valid: %d zones, %d targets\n", common.configFile, len(cfg.Zones), targets)
+	return nil
+}
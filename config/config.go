@@ -1,8 +1,13 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
+	"net/netip"
 	"os"
+	"reflect"
+	"strings"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -28,6 +33,52 @@ type Config struct {
 
 	// Synchronization settings
 	Sync SyncConfig `yaml:"sync" json:"sync"`
+
+	// Audit journal of applied changes
+	Journal JournalConfig `yaml:"journal,omitempty" json:"journal,omitempty"`
+
+	// Snapshots of target records taken before applying changes, used for rollbacks
+	Snapshots SnapshotConfig `yaml:"snapshots,omitempty" json:"snapshots,omitempty"`
+}
+
+// SnapshotConfig configures the local store of target snapshots
+type SnapshotConfig struct {
+	// Directory holding the snapshots, snapshots are disabled when empty
+	Dir string `yaml:"dir,omitempty" json:"dir,omitempty"`
+
+	// Number of snapshots to keep per zone and target (default: 20)
+	Keep int `yaml:"keep,omitempty" json:"keep,omitempty"`
+}
+
+// Hash returns a hash identifying the configuration, used to tell which configuration applied a change
+func (c Config) Hash() string {
+	data, _ := yaml.Marshal(c)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Zone returns the configuration of the zone with the given name, ignoring case and any trailing dot,
+// or nil if the zone is not configured
+func (c Config) Zone(name string) *ZoneConfig {
+	name = strings.TrimSuffix(strings.ToLower(name), ".")
+	for _, zone := range c.Zones {
+		if strings.TrimSuffix(strings.ToLower(zone.Name), ".") == name {
+			return zone
+		}
+	}
+	return nil
+}
+
+// JournalConfig configures the append-only journal of applied changes
+type JournalConfig struct {
+	// Path of the journal file, the journal is disabled when empty
+	Path string `yaml:"path,omitempty" json:"path,omitempty"`
+
+	// Rotate the journal once it reaches this size in megabytes (default: 100)
+	MaxSizeMB int `yaml:"max_size_mb,omitempty" json:"max_size_mb,omitempty"`
+
+	// Number of rotated journal files to keep (default: 10)
+	MaxFiles int `yaml:"max_files,omitempty" json:"max_files,omitempty"`
 }
 
 type Spec Config
@@ -43,14 +94,109 @@ type SyncConfig struct {
 	// Port to listen for DNS NOTIFY messages
 	NotifyPort int `yaml:"notify_port" json:"notify_port"`
 
+	// Sources and rate of the DNS NOTIFY messages that trigger syncs
+	Notify NotifyConfig `yaml:"notify" json:"notify"`
+
 	// Test mode - shows what would be changed without making changes
 	DryRun bool `yaml:"dry_run" json:"dry_run"`
 
-	// Remove records from target that don't exist in source
-	DeleteOrphaned bool `yaml:"delete_orphaned" json:"delete_orphaned"`
+	// Remove records from target that don't exist in source (default: true).
+	// When disabled deletes are never sent, regardless of the zone or target policy
+	DeleteOrphaned *bool `yaml:"delete_orphaned" json:"delete_orphaned"`
 
-	// Override TTL for all records (0 = use source TTL)
+	// Fixed TTL for all records unless a zone or target TTL policy sets one (0 = use source TTL)
 	RecordTTL uint32 `yaml:"record_ttl" json:"record_ttl"`
+
+	// Retries of failed provider calls
+	Retry RetryConfig `yaml:"retry" json:"retry"`
+
+	// Circuit breaker for the source and each target of a zone
+	CircuitBreaker CircuitBreakerConfig `yaml:"circuit_breaker" json:"circuit_breaker"`
+
+	// Rate limit of calls to each provider account, shared by all zones using the account
+	RateLimit RateLimitConfig `yaml:"rate_limit" json:"rate_limit"`
+}
+
+// NotifyConfig restricts the DNS NOTIFY messages that trigger syncs, others are refused
+type NotifyConfig struct {
+	// Networks NOTIFY messages are accepted from, as CIDRs or addresses (default: any)
+	AllowedSources []string `yaml:"allowed_sources,omitempty" json:"allowed_sources,omitempty"`
+
+	// TSIG key NOTIFY messages must be signed with, unsigned messages are refused when set
+	TSIGKeyName string `yaml:"tsig_key_name,omitempty" json:"tsig_key_name,omitempty"`
+
+	// Base64 encoded secret of the TSIG key
+	TSIGSecret string `yaml:"tsig_secret,omitempty" json:"tsig_secret,omitempty" secure:"yes"`
+
+	// Minimum time between syncs of a zone triggered by NOTIFY, further messages are acknowledged and
+	// ignored (default: 10s)
+	MinInterval time.Duration `yaml:"min_interval" json:"min_interval"`
+}
+
+// AllowedNetworks returns the networks NOTIFY messages are accepted from, none meaning any
+func (n NotifyConfig) AllowedNetworks() ([]netip.Prefix, error) {
+	networks := make([]netip.Prefix, 0, len(n.AllowedSources))
+	for _, source := range n.AllowedSources {
+		network, err := parseNetwork(source)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// parseNetwork parses a CIDR, or a single address as a host network
+func parseNetwork(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		network, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid network %q", value)
+		}
+		return network.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid network %q", value)
+	}
+	return netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()), nil
+}
+
+// RateLimitConfig configures a token bucket limiting the calls made to a provider account, where a call
+// is listing the records of a zone or applying changes to it
+type RateLimitConfig struct {
+	// Sustained calls per second (0 = unlimited)
+	RequestsPerSecond float64 `yaml:"requests_per_second" json:"requests_per_second"`
+
+	// Calls that can be made at once before the limit applies (default: 1)
+	Burst int `yaml:"burst" json:"burst"`
+}
+
+// RetryConfig configures retries of failed calls listing the records of a provider, with exponential backoff
+// and jitter. Changes are never retried as a failed call may have applied part of them.
+type RetryConfig struct {
+	// Number of attempts per provider call, 1 disables retries (default: 3)
+	Attempts int `yaml:"attempts" json:"attempts"`
+
+	// Delay before the first retry, doubled for every further retry (default: 1s)
+	InitialBackoff time.Duration `yaml:"initial_backoff" json:"initial_backoff"`
+
+	// Maximum delay between retries (default: 30s)
+	MaxBackoff time.Duration `yaml:"max_backoff" json:"max_backoff"`
+}
+
+// CircuitBreakerConfig configures the circuit breaker that stops calling an unhealthy provider
+type CircuitBreakerConfig struct {
+	// Consecutive failed provider calls, after retries, that mark a provider unhealthy (0 = disabled)
+	Failures int `yaml:"failures" json:"failures"`
+
+	// Time to wait before probing an unhealthy provider again (default: 1m)
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+}
+
+// DeleteOrphanedRecords returns true unless deleting orphaned records has been disabled
+func (s SyncConfig) DeleteOrphanedRecords() bool {
+	return s.DeleteOrphaned == nil || *s.DeleteOrphaned
 }
 
 // SourceConfig defines the source DNS server configuration
@@ -65,6 +211,99 @@ type SourceConfig struct {
 // TargetConfig defines target DNS provider configuration
 type TargetConfig struct {
 	ProviderConfig `yaml:",inline" json:",inline"`
+
+	// Sync policy for this target (sync, upsert-only, create-only), overrides the zone policy
+	Policy string `yaml:"policy,omitempty" json:"policy,omitempty"`
+
+	// TXT ownership registry, when set only records created by this owner are updated or deleted
+	Registry *RegistryConfig `yaml:"registry,omitempty" json:"registry,omitempty"`
+
+	// Publish the records of the zone under a differently named zone on this target
+	Rename *RenameConfig `yaml:"rename,omitempty" json:"rename,omitempty"`
+
+	// Rewrite record values published on this target, e.g. to publish public addresses of internal records
+	Rewrite *RewriteConfig `yaml:"rewrite,omitempty" json:"rewrite,omitempty"`
+
+	// TTL policy for this target, fields that are set override the zone TTL policy
+	TTL *TTLPolicyConfig `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+
+	// Only sync records matching this filter to the target, applied in addition to the zone record filter
+	RecordFilter *RecordFilterConfig `yaml:"record_filter,omitempty" json:"record_filter,omitempty"`
+
+	// Safety thresholds for this target, fields that are set override the zone thresholds
+	Safety *SafetyConfig `yaml:"safety,omitempty" json:"safety,omitempty"`
+
+	// Only manage records within these domains and zones on the target, replacing the source domain filter.
+	// Records outside of the filter are neither created nor deleted
+	DomainFilter *DomainFilterConfig `yaml:"domain_filter,omitempty" json:"domain_filter,omitempty"`
+}
+
+// SafetyConfig contains thresholds that refuse to apply plans that would remove too many records,
+// e.g. after a source returned an empty or truncated zone
+type SafetyConfig struct {
+	// Refuse plans deleting more than this number of records (0 = no limit)
+	MaxDeletes int `yaml:"max_deletes,omitempty" json:"max_deletes,omitempty"`
+
+	// Refuse plans deleting more than this percentage of the records on the target (0 = no limit)
+	MaxDeletePercent float64 `yaml:"max_delete_percent,omitempty" json:"max_delete_percent,omitempty"`
+
+	// Refuse plans when fewer records than this are desired on the target (0 = no limit)
+	MinRecords int `yaml:"min_records,omitempty" json:"min_records,omitempty"`
+}
+
+// TTLPolicyConfig controls the TTL of records published to a target
+type TTLPolicyConfig struct {
+	// Fixed TTL in seconds for all records (0 = use source TTL)
+	TTL uint32 `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+
+	// Raise TTLs below this value, in seconds
+	Min uint32 `yaml:"min,omitempty" json:"min,omitempty"`
+
+	// Lower TTLs above this value, in seconds
+	Max uint32 `yaml:"max,omitempty" json:"max,omitempty"`
+
+	// Fixed TTL in seconds by record type, e.g. MX: 3600, takes precedence over ttl (0 = use source TTL)
+	Types map[string]uint32 `yaml:"types,omitempty" json:"types,omitempty"`
+}
+
+// RewriteConfig maps record values of the source zone to the values published on a target
+type RewriteConfig struct {
+	// A and AAAA targets, keyed by source IP or CIDR, mapped to an IP or CIDR of the same size.
+	// The most specific match wins and host bits are preserved when mapping between networks
+	Addresses map[string]string `yaml:"addresses,omitempty" json:"addresses,omitempty"`
+
+	// CNAME targets, keyed by source domain suffix, the longest matching suffix wins
+	CNAMEs map[string]string `yaml:"cnames,omitempty" json:"cnames,omitempty"`
+}
+
+// RenameConfig maps the records of a source zone into a differently named target zone
+type RenameConfig struct {
+	// Zone suffix to replace, defaults to the zone name
+	From string `yaml:"from,omitempty" json:"from,omitempty"`
+
+	// Zone suffix used on the target
+	To string `yaml:"to" json:"to"`
+
+	// Also rewrite CNAME, MX and SRV targets that point into the renamed zone
+	RewriteTargets bool `yaml:"rewrite_targets,omitempty" json:"rewrite_targets,omitempty"`
+}
+
+// RegistryConfig configures the TXT records used to track ownership of records on a target
+type RegistryConfig struct {
+	// Unique identifier of this dns-sync instance (required)
+	OwnerID string `yaml:"owner_id" json:"owner_id"`
+
+	// Prefix for ownership TXT record names, mutually exclusive with suffix
+	Prefix string `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+
+	// Suffix for ownership TXT record names, mutually exclusive with prefix
+	Suffix string `yaml:"suffix,omitempty" json:"suffix,omitempty"`
+
+	// Replacement for the leading asterisk in ownership TXT record names of wildcard records
+	WildcardReplacement string `yaml:"wildcard_replacement,omitempty" json:"wildcard_replacement,omitempty"`
+
+	// AES-256 key, 32 bytes in plain text or base64, enables encryption of ownership TXT records (optional)
+	EncryptionKey string `yaml:"encryption_key,omitempty" json:"encryption_key,omitempty" secure:"yes"`
 }
 
 // ProviderConfig contains provider-specific configurations
@@ -93,6 +332,9 @@ type ProviderConfig struct {
 	Webhook      *WebhookProviderConfig      `yaml:"webhook,omitempty" json:"webhook,omitempty"`
 	InMemory     *InMemoryProviderConfig     `yaml:"inmemory,omitempty" json:"inmemory,omitempty"`
 	File         *FileProviderConfig         `yaml:"file,omitempty" json:"file,omitempty"`
+
+	// Rate limit of calls to the provider account, overrides sync.rate_limit
+	RateLimit *RateLimitConfig `yaml:"rate_limit,omitempty" json:"rate_limit,omitempty"`
 }
 
 func (p ProviderConfig) String() string {
@@ -136,6 +378,70 @@ func (p ProviderConfig) String() string {
 	return "Unknown"
 }
 
+// Account identifies the account used to call the provider: its type along with the credentials, profile
+// or server it connects to. Secrets are hashed so that the account can be logged.
+func (p ProviderConfig) Account() string {
+	if p.AWS != nil {
+		return fmt.Sprintf("AWS{profiles=%s,role=%s}", strings.Join(p.AWS.Profiles, ","), p.AWS.AssumeRole)
+	} else if p.Azure != nil {
+		return fmt.Sprintf("Azure{config=%s,sub=%s,client=%s}", p.Azure.ConfigFile, p.Azure.SubscriptionID, p.Azure.ClientID)
+	} else if p.Google != nil {
+		return fmt.Sprintf("Google{project=%s}", p.Google.Project)
+	} else if p.Akamai != nil {
+		return fmt.Sprintf("Akamai{host=%s,token=%s,edgerc=%s:%s}", p.Akamai.ServiceConsumerDomain, secretHash(p.Akamai.ClientToken),
+			p.Akamai.EdgercPath, p.Akamai.EdgercSection)
+	} else if p.OCI != nil {
+		return fmt.Sprintf("OCI{config=%s,compartment=%s}", p.OCI.ConfigFile, p.OCI.CompartmentOCID)
+	} else if p.OVH != nil {
+		return fmt.Sprintf("OVH{endpoint=%s}", p.OVH.Endpoint)
+	} else if p.PowerDNS != nil {
+		return fmt.Sprintf("PowerDNS{server=%s,key=%s}", p.PowerDNS.Server, secretHash(p.PowerDNS.APIKey))
+	} else if p.NS1 != nil {
+		return fmt.Sprintf("NS1{endpoint=%s}", p.NS1.Endpoint)
+	} else if p.IBMCloud != nil {
+		return fmt.Sprintf("IBMCloud{config=%s}", p.IBMCloud.ConfigFile)
+	} else if p.GoDaddy != nil {
+		return fmt.Sprintf("GoDaddy{key=%s}", secretHash(p.GoDaddy.APIKey))
+	} else if p.Exoscale != nil {
+		return fmt.Sprintf("Exoscale{key=%s}", secretHash(p.Exoscale.APIKey))
+	} else if p.RFC2136 != nil {
+		return fmt.Sprintf("RFC2136{host=%s}", strings.Join(p.RFC2136.Host, ","))
+	} else if p.AlibabaCloud != nil {
+		return fmt.Sprintf("AlibabaCloud{config=%s}", p.AlibabaCloud.ConfigFile)
+	} else if p.TencentCloud != nil {
+		return fmt.Sprintf("TencentCloud{config=%s}", p.TencentCloud.ConfigFile)
+	} else if p.CloudFoundry != nil {
+		return fmt.Sprintf("CloudFoundry{api=%s,user=%s}", p.CloudFoundry.APIEndpoint, p.CloudFoundry.Username)
+	} else if p.TransIP != nil {
+		return fmt.Sprintf("TransIP{account=%s}", p.TransIP.AccountName)
+	} else if p.Pihole != nil {
+		return fmt.Sprintf("Pihole{server=%s}", p.Pihole.Server)
+	} else if p.Webhook != nil {
+		return fmt.Sprintf("Webhook{url=%s}", p.Webhook.URL)
+	}
+	// The remaining providers take their credentials from the environment, or have none
+	return p.String()
+}
+
+// secretHash returns a short hash of a secret, empty when the secret is not set
+func secretHash(secret string) string {
+	if secret == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:4])
+}
+
+// MinTTL returns the minimum TTL in seconds enforced by the provider, 0 if there is none
+func (p ProviderConfig) MinTTL() uint32 {
+	if p.NS1 != nil && p.NS1.MinTTLSeconds > 0 {
+		return uint32(p.NS1.MinTTLSeconds)
+	} else if p.RFC2136 != nil && p.RFC2136.MinTTL > 0 {
+		return uint32(p.RFC2136.MinTTL.Seconds())
+	}
+	return 0
+}
+
 type FileProviderConfig struct {
 	// Path to the file containing DNS records
 	Path string `yaml:"path" json:"path"`
@@ -169,6 +475,15 @@ type ZoneConfig struct {
 
 	// Record filtering configuration
 	RecordFilter RecordFilterConfig `yaml:"record_filter" json:"record_filter"`
+
+	// Sync policy for all targets of this zone (sync, upsert-only, create-only), defaults to sync
+	Policy string `yaml:"policy,omitempty" json:"policy,omitempty"`
+
+	// TTL policy for all targets of this zone
+	TTL *TTLPolicyConfig `yaml:"ttl,omitempty" json:"ttl,omitempty"`
+
+	// Safety thresholds for all targets of this zone
+	Safety *SafetyConfig `yaml:"safety,omitempty" json:"safety,omitempty"`
 }
 
 // Load reads and parses the configuration file
@@ -177,28 +492,78 @@ func Load(configFile string) (*Config, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read config file: %w", err)
 	}
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("failed to parse config file: %w", err)
+	}
+	if err := interpolate(&document); err != nil {
+		return nil, fmt.Errorf("failed to interpolate config file: %w", err)
+	}
 
+	// Unknown keys are rejected, as they are usually misspelled options that would be silently ignored
 	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
+	if err := decodeDocument(nil, &document, &config); err != nil {
 		return nil, fmt.Errorf("failed to parse config file: %w", err)
 	}
 
-	d, _ := yaml.Marshal(config)
-	fmt.Println(string(d))
+	v := newValidator(&document)
+	v.resolveSecrets(nil, reflect.ValueOf(&config).Elem())
+	if err := v.err(); err != nil {
+		return nil, fmt.Errorf("failed to resolve secrets in %s: %w", configFile, err)
+	}
 
 	// Set defaults
 	if err := setDefaults(&config); err != nil {
 		return nil, fmt.Errorf("failed to set defaults: %w", err)
 	}
 
-	// // Validate configuration
-	// if err := validate(&config); err != nil {
-	// 	return nil, fmt.Errorf("invalid configuration: %w", err)
-	// }
+	if err := validate(&config, &document); err != nil {
+		return nil, fmt.Errorf("invalid configuration in %s: %w", configFile, err)
+	}
+
+	return &config, nil
+}
+
+// ParseProvider parses a provider configuration given inline as YAML, e.g. on the command line as
+// "file: {path: zone.db}", interpolating environment variables and resolving secrets as Load does.
+// Errors are reported under name.
+func ParseProvider(name, data string) (*ProviderConfig, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &document); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
+	if err := interpolate(&document); err != nil {
+		return nil, err
+	}
+
+	var config ProviderConfig
+	if err := decodeDocument(path{name}, &document, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", name, err)
+	}
 
+	v := newValidator(&document)
+	v.resolveSecrets(path{name}, reflect.ValueOf(&config).Elem())
+	v.validateProvider(path{name}, config)
+	if err := v.err(); err != nil {
+		return nil, err
+	}
 	return &config, nil
 }
 
+// decodeDocument decodes an interpolated document into out, rejecting keys that do not match a field of out
+func decodeDocument(p path, document *yaml.Node, out any) error {
+	v := newValidator(document)
+	v.knownFields(p, document, reflect.TypeOf(out))
+	if err := v.err(); err != nil {
+		return err
+	}
+	if document.Kind == 0 {
+		// Empty document
+		return nil
+	}
+	return document.Decode(out)
+}
+
 // setDefaults applies default values to the configuration
 func setDefaults(config *Config) error {
 	// Core defaults
@@ -222,9 +587,20 @@ func setDefaults(config *Config) error {
 	if config.Sync.NotifyPort == 0 {
 		config.Sync.NotifyPort = 5353
 	}
-	if config.Sync.RecordTTL == 0 {
-		config.Sync.RecordTTL = 300
+	if config.Sync.Notify.MinInterval == 0 {
+		config.Sync.Notify.MinInterval = 10 * time.Second
+	}
+	if config.Sync.Retry.Attempts == 0 {
+		config.Sync.Retry.Attempts = 3
+	}
+	if config.Sync.Retry.InitialBackoff == 0 {
+		config.Sync.Retry.InitialBackoff = time.Second
+	}
+	if config.Sync.Retry.MaxBackoff == 0 {
+		config.Sync.Retry.MaxBackoff = 30 * time.Second
+	}
+	if config.Sync.CircuitBreaker.Timeout == 0 {
+		config.Sync.CircuitBreaker.Timeout = time.Minute
 	}
-
 	return nil
 }
@@ -0,0 +1,350 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadExample(t *testing.T) {
+	_, err := Load("../config.yaml.example")
+	require.NoError(t, err)
+}
+
+func TestValidate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		errors []string
+	}{
+		{
+			name: "valid",
+			config: `
+zones:
+  - name: example.com
+    source:
+      file:
+        path: zone.bind
+    targets:
+      - inmemory: {}
+`,
+		},
+		{
+			name: "providers",
+			config: `
+zones:
+  - name: example.com
+    source:
+      file:
+        path: zone.bind
+      inmemory: {}
+    targets:
+      - policy: sync
+      - powerdns:
+          server: https://pdns.example.com
+      - webhook:
+          url: https://webhook.example.com
+`,
+			errors: []string{
+				"line 4: zones[0].source: exactly one provider must be configured, found inmemory, file",
+				"line 9: zones[0].targets[0]: no provider configured",
+				"line 10: zones[0].targets[1].powerdns.api_key: is required",
+				"line 12: zones[0].targets[2].webhook: provider webhook is not supported",
+			},
+		},
+		{
+			name: "values",
+			config: `
+metrics_address: ":99999"
+sync:
+  interval: 10ms
+zones:
+  - name: example.com
+    record_filter:
+      include_types: [A, AAAA, ALIAS]
+    source:
+      file:
+        path: zone.bind
+    targets:
+      - inmemory: {}
+        policy: mirror
+        safety:
+          max_delete_percent: 150
+  - name: Example.com.
+    source:
+      file:
+        path: zone.bind
+    targets: []
+`,
+			errors: []string{
+				`line 2: metrics_address: invalid port "99999"`,
+				"line 4: sync.interval: must be at least 1s, got 10ms",
+				`line 8: zones[0].record_filter.include_types: unknown record type "ALIAS"`,
+				`line 14: zones[0].targets[0].policy: unknown policy "mirror", must be one of sync, upsert-only, create-only`,
+				"line 16: zones[0].targets[0].safety.max_delete_percent: must be between 0 and 100",
+				"line 21: zones[1].targets: at least one target is required",
+				"line 17: zones[1].name: zone Example.com. is already configured by zones[0]",
+			},
+		},
+		{
+			name: "patterns",
+			config: `
+zones:
+  - name: example.com
+    record_filter:
+      include_names: ["*.example.com"]
+      exclude_names:
+        - "regex:^internal-\\d+"
+        - "regex:(unclosed"
+        - "[a-"
+    source:
+      file:
+        path: zone.bind
+    targets:
+      - inmemory: {}
+        record_filter:
+          include_names: ["regex:*"]
+`,
+			errors: []string{
+				"line 8: zones[0].record_filter.exclude_names[1]: invalid pattern \"regex:(unclosed\": error parsing regexp: missing closing ): `(?i)(unclosed`",
+				`line 9: zones[0].record_filter.exclude_names[2]: invalid pattern "[a-": syntax error in pattern`,
+				"line 16: zones[0].targets[0].record_filter.include_names[0]: invalid pattern \"regex:*\": error parsing regexp: missing argument to repetition operator: `*`",
+			},
+		},
+		{
+			name: "notify",
+			config: `
+sync:
+  notify:
+    allowed_sources: [192.0.2.0/24, "2001:db8::1", 10.0.0.0/33, primary]
+    tsig_key_name: notify-key
+    min_interval: -1s
+zones:
+  - name: example.com
+    source:
+      file:
+        path: zone.bind
+    targets:
+      - inmemory: {}
+`,
+			errors: []string{
+				`line 4: sync.notify.allowed_sources[2]: invalid network "10.0.0.0/33", expected a CIDR or an address`,
+				`line 4: sync.notify.allowed_sources[3]: invalid network "primary", expected a CIDR or an address`,
+				"line 3: sync.notify.tsig_secret: is required with tsig_key_name",
+				"line 6: sync.notify.min_interval: must not be negative",
+			},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			require.NoError(t, os.WriteFile(path, []byte(tc.config), 0600))
+
+			_, err := Load(path)
+			if len(tc.errors) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			var validationErr *ValidationError
+			require.ErrorAs(t, err, &validationErr)
+			var messages []string
+			for _, fieldErr := range validationErr.Errors {
+				messages = append(messages, fieldErr.Error())
+			}
+			assert.Equal(t, tc.errors, messages)
+		})
+	}
+
+	// Unknown keys are rejected along with their line
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("sync:\n  intervall: 5m\n"), 0600))
+	_, err := Load(path)
+	assert.ErrorContains(t, err, "line 2: sync.intervall: unknown field")
+}
+
+func TestSecrets(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "tsig.key"), []byte("tsig-from-file\n"), 0600))
+	t.Setenv("DNS_HOST", "192.0.2.53")
+	t.Setenv("PDNS_KEY", "key-from-env")
+	t.Setenv("EMPTY", "")
+
+	path := filepath.Join(dir, "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+# Comments are not interpolated: ${UNSET}
+sync:
+  interval: ${SYNC_INTERVAL:-2m}
+zones:
+  - name: example.com
+    source:
+      rfc2136:
+        host: ["${DNS_HOST}"]
+        tsig_key_name: "key$$1"
+        tsig_secret: "file:`+filepath.Join(dir, "tsig.key")+`"
+    targets:
+      - powerdns:
+          server: "https://${EMPTY:-pdns.example.com}"
+          api_key: env:PDNS_KEY
+        registry:
+          owner_id: dns-sync
+          encryption_key: base64:MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=
+`), 0600))
+
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 2*time.Minute, cfg.Sync.Interval)
+	rfc2136 := cfg.Zones[0].Source.RFC2136
+	assert.Equal(t, []string{"192.0.2.53"}, rfc2136.Host)
+	assert.Equal(t, "key$1", rfc2136.TSIGKeyName)
+	assert.Equal(t, "tsig-from-file", rfc2136.TSIGSecret)
+	target := cfg.Zones[0].Targets[0]
+	assert.Equal(t, "https://pdns.example.com", target.PowerDNS.Server)
+	assert.Equal(t, "key-from-env", target.PowerDNS.APIKey)
+	assert.Equal(t, "0123456789abcdef0123456789abcdef", target.Registry.EncryptionKey)
+
+	// Unset variables and secrets fail with their line
+	require.NoError(t, os.WriteFile(path, []byte("sync:\n  interval: ${UNSET_INTERVAL}\n"), 0600))
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "line 2: environment variable UNSET_INTERVAL is not set")
+
+	require.NoError(t, os.WriteFile(path, []byte(`
+zones:
+  - name: example.com
+    source:
+      powerdns:
+        server: https://pdns.example.com
+        api_key: env:UNSET_KEY
+    targets:
+      - inmemory: {}
+`), 0600))
+	_, err = Load(path)
+	assert.ErrorContains(t, err, "line 7: zones[0].source.powerdns.api_key: environment variable UNSET_KEY is not set")
+}
+
+func TestInterpolate(t *testing.T) {
+	t.Setenv("TSIG_SECRET", "s3cr3t: \"quoted\" #1\n  second: line")
+	t.Setenv("NOTIFY_PORT", "5300")
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(`
+sync:
+  notify_port: ${NOTIFY_PORT} # ${UNSET_TRAILING}
+#  interval: ${UNSET_COMMENTED}
+zones:
+  - name: example.com
+    source:
+      rfc2136:
+        host: [192.0.2.53]
+        tsig_key_name: key
+        tsig_secret: ${TSIG_SECRET}
+    targets:
+      - inmemory: {}
+`), 0600))
+
+	// Values are never read as YAML, plain values keep their type and comments are ignored
+	cfg, err := Load(path)
+	require.NoError(t, err)
+	assert.Equal(t, 5300, cfg.Sync.NotifyPort)
+	assert.Equal(t, "s3cr3t: \"quoted\" #1\n  second: line", cfg.Zones[0].Source.RFC2136.TSIGSecret)
+	assert.Len(t, cfg.Zones, 1)
+}
+
+func TestRedacted(t *testing.T) {
+	cfg := Config{
+		Zones: []*ZoneConfig{
+			{
+				Name: "example.com",
+				Source: SourceConfig{ProviderConfig: ProviderConfig{
+					RFC2136: &RFC2136ProviderConfig{TSIGKeyName: "key", TSIGSecret: "tsig-secret"},
+				}},
+				Targets: []TargetConfig{
+					{
+						ProviderConfig: ProviderConfig{Akamai: &AkamaiProviderConfig{ClientSecret: "akamai-secret"}},
+						Registry:       &RegistryConfig{OwnerID: "owner", EncryptionKey: "encryption-key"},
+					},
+				},
+			},
+		},
+	}
+
+	redacted := cfg.Redacted()
+	assert.Equal(t, "key", redacted.Zones[0].Source.RFC2136.TSIGKeyName)
+	assert.Equal(t, "*****", redacted.Zones[0].Source.RFC2136.TSIGSecret)
+	assert.Equal(t, "", redacted.Zones[0].Source.RFC2136.KerberosPassword)
+	assert.Equal(t, "*****", redacted.Zones[0].Targets[0].Akamai.ClientSecret)
+	assert.Equal(t, "*****", redacted.Zones[0].Targets[0].Registry.EncryptionKey)
+
+	// The original configuration is left untouched
+	assert.Equal(t, "tsig-secret", cfg.Zones[0].Source.RFC2136.TSIGSecret)
+	assert.Equal(t, "akamai-secret", cfg.Zones[0].Targets[0].Akamai.ClientSecret)
+	assert.Equal(t, "encryption-key", cfg.Zones[0].Targets[0].Registry.EncryptionKey)
+
+	for _, secret := range []string{"tsig-secret", "akamai-secret", "encryption-key"} {
+		assert.NotContains(t, cfg.String(), secret)
+	}
+}
+
+func TestZone(t *testing.T) {
+	cfg := Config{Zones: []*ZoneConfig{{Name: "example.com"}, {Name: "Example.org."}}}
+	assert.Equal(t, cfg.Zones[0], cfg.Zone("example.com."))
+	assert.Equal(t, cfg.Zones[1], cfg.Zone("example.ORG"))
+	assert.Nil(t, cfg.Zone("example.net"))
+}
+
+func TestParseProvider(t *testing.T) {
+	t.Setenv("ZONE_FILE", "zone.db")
+	t.Setenv("PDNS_API_KEY", "secret")
+
+	provider, err := ParseProvider("left", `file: {path: "${ZONE_FILE}"}`)
+	require.NoError(t, err)
+	assert.Equal(t, "zone.db", provider.File.Path)
+
+	provider, err = ParseProvider("left", "powerdns: {server: http://127.0.0.1:8081, api_key: env:PDNS_API_KEY}")
+	require.NoError(t, err)
+	assert.Equal(t, "secret", provider.PowerDNS.APIKey)
+
+	_, err = ParseProvider("right", "file: {}")
+	assert.EqualError(t, err, "line 1: right.file.path: is required")
+	_, err = ParseProvider("right", "")
+	assert.EqualError(t, err, "right: no provider configured")
+	_, err = ParseProvider("right", "file: {pth: zone.db}")
+	assert.EqualError(t, err, "failed to parse right: line 1: right.file.pth: unknown field")
+}
+
+func TestNamePattern(t *testing.T) {
+	tests := []struct {
+		name    string
+		pattern string
+		match   bool
+	}{
+		{"www.example.com", "*", true},
+		{"www.example.com", "www.example.com", true},
+		{"WWW.Example.com.", "www.example.com", true},
+		{"www.example.com", "www.example.com.", true},
+		{"v1.api.example.com", "*.api.example.com", true},
+		{"api.example.com", "*.api.example.com", false},
+		{"temp.example.com", "temp.*", true},
+		{"temporary.example.com", "temp.*", false},
+		{"test1.example.com", "test?.example.com", true},
+		{"testa.example.com", "test[0-9].example.com", false},
+		{"build-42.example.com", `regex:^build-\d+\.`, true},
+		{"BUILD-42.example.com", `regex:^build-\d+\.`, true},
+		{"build-x.example.com", `regex:^build-\d+\.`, false},
+	}
+
+	for _, tc := range tests {
+		pattern, err := CompileNamePattern(tc.pattern)
+		require.NoError(t, err, tc.pattern)
+		assert.Equal(t, tc.match, pattern.Match(tc.name), "%s ~ %s", tc.name, tc.pattern)
+	}
+
+	for _, invalid := range []string{"regex:(", "[", "www.[a-"} {
+		_, err := CompileNamePattern(invalid)
+		assert.Error(t, err, invalid)
+	}
+}
@@ -18,7 +18,8 @@ package config
 
 import (
 	"fmt"
-	"reflect"
+
+	"gopkg.in/yaml.v3"
 )
 
 // var defaultConfig = &ConfigSpec{
@@ -193,25 +194,13 @@ func NewConfig() *Config {
 	}
 }
 
+// String returns the configuration as YAML, with secure fields redacted
 func (cfg *Config) String() string {
-	// prevent logging of sensitive information
-	temp := *cfg
-
-	t := reflect.TypeOf(temp)
-	for i := 0; i < t.NumField(); i++ {
-		f := t.Field(i)
-		if val, ok := f.Tag.Lookup("secure"); ok && val == "yes" {
-			if f.Type.Kind() != reflect.String {
-				continue
-			}
-			v := reflect.ValueOf(&temp).Elem().Field(i)
-			if v.String() != "" {
-				v.SetString("*****")
-			}
-		}
+	data, err := yaml.Marshal(cfg.Redacted())
+	if err != nil {
+		return fmt.Sprintf("invalid configuration: %v", err)
 	}
-
-	return fmt.Sprintf("%+v", temp)
+	return string(data)
 }
 
 // // allLogLevelsAsStrings returns all logrus levels as a list of strings
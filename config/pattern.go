@@ -0,0 +1,51 @@
+package config
+
+import (
+	pathpkg "path"
+	"regexp"
+	"strings"
+)
+
+// regexPrefix marks a record filter name pattern as a regular expression instead of a glob
+const regexPrefix = "regex:"
+
+// NamePattern is a compiled record filter name pattern
+type NamePattern struct {
+	glob  string
+	regex *regexp.Regexp
+}
+
+// CompileNamePattern compiles a record filter name pattern. Patterns are shell-style globs (*, ? and [...])
+// unless prefixed with "regex:". Matching is case-insensitive and ignores trailing dots, regular
+// expressions are not anchored.
+func CompileNamePattern(pattern string) (*NamePattern, error) {
+	if expr, ok := strings.CutPrefix(pattern, regexPrefix); ok {
+		re, err := regexp.Compile("(?i)" + expr)
+		if err != nil {
+			return nil, err
+		}
+		return &NamePattern{regex: re}, nil
+	}
+
+	glob := normalizeName(pattern)
+	// Match checks the whole pattern even when the name doesn't match
+	if _, err := pathpkg.Match(glob, ""); err != nil {
+		return nil, err
+	}
+	return &NamePattern{glob: glob}, nil
+}
+
+// Match returns true when the record name matches the pattern
+func (p *NamePattern) Match(name string) bool {
+	name = normalizeName(name)
+	if p.regex != nil {
+		return p.regex.MatchString(name)
+	}
+	matched, _ := pathpkg.Match(p.glob, name)
+	return matched
+}
+
+// normalizeName lower cases a DNS name and removes its trailing dot
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
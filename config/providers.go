@@ -1,8 +1,11 @@
 package config
 
 import (
+	"fmt"
 	"regexp"
 	"time"
+
+	"sigs.k8s.io/external-dns/endpoint"
 )
 
 // AWSProviderConfig contains AWS Route53 specific configuration
@@ -130,13 +133,13 @@ type AkamaiProviderConfig struct {
 	ServiceConsumerDomain string `yaml:"service_consumer_domain" json:"service_consumer_domain"`
 
 	// When using the Akamai provider, specify the client token (required when edgerc-path not specified)
-	ClientToken string `yaml:"client_token" json:"client_token"`
+	ClientToken string `yaml:"client_token" json:"client_token" secure:"yes"`
 
 	// When using the Akamai provider, specify the client secret (required when edgerc-path not specified)
-	ClientSecret string `yaml:"client_secret" json:"client_secret"`
+	ClientSecret string `yaml:"client_secret" json:"client_secret" secure:"yes"`
 
 	// When using the Akamai provider, specify the access token (required when edgerc-path not specified)
-	AccessToken string `yaml:"access_token" json:"access_token"`
+	AccessToken string `yaml:"access_token" json:"access_token" secure:"yes"`
 
 	// When using the Akamai provider, specify the .edgerc file path.
 	// Path must be reachable from invocation environment
@@ -346,7 +349,7 @@ type CloudFoundryProviderConfig struct {
 	Username string `yaml:"username" json:"username"`
 
 	// The password to log into the cloud foundry API
-	Password string `yaml:"password" json:"password"`
+	Password string `yaml:"password" json:"password" secure:"yes"`
 }
 
 // CoreDNSProviderConfig contains CoreDNS specific configuration
@@ -430,10 +433,10 @@ type DomainFilterConfig struct {
 	ExcludeDomains []string `yaml:"exclude_domains" json:"exclude_domains"`
 
 	// Limit possible domains and target zones by a Regex filter; Overrides domain-filter (optional)
-	RegexDomainFilter *regexp.Regexp `yaml:"regex_domain_filter" json:"regex_domain_filter"`
+	RegexDomainFilter string `yaml:"regex_domain_filter" json:"regex_domain_filter"`
 
 	// Regex filter that excludes domains and target zones matched by regex-domain-filter (optional)
-	RegexDomainExclusion *regexp.Regexp `yaml:"regex_domain_exclusion" json:"regex_domain_exclusion"`
+	RegexDomainExclusion string `yaml:"regex_domain_exclusion" json:"regex_domain_exclusion"`
 
 	// Filter target zones by zone domain; specify multiple times for multiple zones (optional)
 	ZoneNameFilter []string `yaml:"zone_name_filter" json:"zone_name_filter"`
@@ -441,3 +444,24 @@ type DomainFilterConfig struct {
 	// Filter target zones by hosted zone id; specify multiple times for multiple zones (optional)
 	ZoneIDFilter []string `yaml:"zone_id_filter" json:"zone_id_filter"`
 }
+
+// Filter returns the external-dns domain filter, the regex filters take precedence over the domain lists
+func (d DomainFilterConfig) Filter() (endpoint.DomainFilter, error) {
+	if d.RegexDomainFilter == "" && d.RegexDomainExclusion == "" {
+		return endpoint.NewDomainFilterWithExclusions(d.DomainFilter, d.ExcludeDomains), nil
+	}
+
+	var include, exclude *regexp.Regexp
+	var err error
+	if d.RegexDomainFilter != "" {
+		if include, err = regexp.Compile(d.RegexDomainFilter); err != nil {
+			return endpoint.DomainFilter{}, fmt.Errorf("invalid regex_domain_filter: %w", err)
+		}
+	}
+	if d.RegexDomainExclusion != "" {
+		if exclude, err = regexp.Compile(d.RegexDomainExclusion); err != nil {
+			return endpoint.DomainFilter{}, fmt.Errorf("invalid regex_domain_exclusion: %w", err)
+		}
+	}
+	return endpoint.NewRegexDomainFilter(include, exclude), nil
+}
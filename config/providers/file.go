@@ -9,6 +9,7 @@ import (
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 	"time"
@@ -20,6 +21,9 @@ import (
 	"sigs.k8s.io/external-dns/provider"
 )
 
+// defaultTTL is the TTL of records that don't have one
+const defaultTTL = 300
+
 // fileProvider implements the external-dns Provider interface for reading DNS records from BIND zone files
 type fileProvider struct {
 	config       config.FileProviderConfig
@@ -153,8 +157,8 @@ func (f *fileProvider) convertRRToEndpoint(rr dns.RR) (*endpoint.Endpoint, error
 	case *dns.MX:
 		targets = []string{fmt.Sprintf("%d %s", rr.Preference, strings.TrimSuffix(rr.Mx, "."))}
 	case *dns.TXT:
-		// Join all TXT strings
-		targets = []string{strings.Join(rr.Txt, "")}
+		// Join all TXT strings, the dns library keeps them in escaped presentation format
+		targets = []string{unescapeTXT(strings.Join(rr.Txt, ""))}
 	case *dns.SRV:
 		targets = []string{fmt.Sprintf("%d %d %d %s", rr.Priority, rr.Weight, rr.Port, strings.TrimSuffix(rr.Target, "."))}
 	case *dns.NS:
@@ -179,7 +183,7 @@ func (f *fileProvider) convertRRToEndpoint(rr dns.RR) (*endpoint.Endpoint, error
 // getDefaultTTL returns a consistent TTL value, normalizing 0 values to a default
 func (f *fileProvider) getDefaultTTL(ttl uint32) uint32 {
 	if ttl == 0 {
-		return 300 // Use the same default as endpointToRRs
+		return defaultTTL
 	}
 	return ttl
 }
@@ -208,7 +212,7 @@ func (f *fileProvider) parseZoneFile() ([]dns.RR, error) {
 
 // deleteRecord removes matching records from the records slice
 func (f *fileProvider) deleteRecord(records *[]dns.RR, endpoint *endpoint.Endpoint) {
-	targetRRs := f.endpointToRRs(endpoint)
+	targetRRs := endpointToRRs(endpoint)
 	if len(targetRRs) == 0 {
 		return
 	}
@@ -230,14 +234,24 @@ func (f *fileProvider) deleteRecord(records *[]dns.RR, endpoint *endpoint.Endpoi
 	*records = filtered
 }
 
-// addRecord adds new records to the records slice (one per target)
+// addRecord adds new records to the records slice (one per target), skipping records that already exist
 func (f *fileProvider) addRecord(records *[]dns.RR, endpoint *endpoint.Endpoint) {
-	rrs := f.endpointToRRs(endpoint)
-	*records = append(*records, rrs...)
+	for _, rr := range endpointToRRs(endpoint) {
+		exists := false
+		for _, existing := range *records {
+			if f.recordsMatch(existing, rr) {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			*records = append(*records, rr)
+		}
+	}
 }
 
 // endpointToRRs converts an external-dns endpoint to DNS resource records (one per target)
-func (f *fileProvider) endpointToRRs(endpoint *endpoint.Endpoint) []dns.RR {
+func endpointToRRs(endpoint *endpoint.Endpoint) []dns.RR {
 	var rrs []dns.RR
 
 	// Ensure DNS name has trailing dot for DNS library
@@ -246,7 +260,7 @@ func (f *fileProvider) endpointToRRs(endpoint *endpoint.Endpoint) []dns.RR {
 		dnsName += "."
 	}
 
-	ttl := uint32(300)
+	ttl := uint32(defaultTTL)
 	if endpoint.RecordTTL > 0 && int64(endpoint.RecordTTL) < math.MaxUint32 {
 		ttl = uint32(endpoint.RecordTTL)
 	}
@@ -301,7 +315,7 @@ func (f *fileProvider) endpointToRRs(endpoint *endpoint.Endpoint) []dns.RR {
 		case "TXT":
 			rrs = append(rrs, &dns.TXT{
 				Hdr: header,
-				Txt: []string{target},
+				Txt: []string{escapeTXT(target)},
 			})
 		case "SRV":
 			parts := strings.Fields(target)
@@ -340,14 +354,17 @@ func (f *fileProvider) endpointToRRs(endpoint *endpoint.Endpoint) []dns.RR {
 				Ptr: ptr,
 			})
 		case "SOA":
+			// Only the name server and serial are kept in the target of SOA endpoints, the remaining
+			// fields get the defaults of newSOA
 			parts := strings.Fields(target)
 			if len(parts) >= 2 {
 				serial, _ := strconv.ParseUint(parts[1], 10, 32)
-				rrs = append(rrs, &dns.SOA{
-					Hdr:    header,
-					Ns:     parts[0],
-					Serial: uint32(serial),
-				})
+				rrs = append(rrs, newSOA(header, parts[0], uint32(serial)))
+			}
+		default:
+			// Other types hold their data in presentation format, as read by convertRRToEndpoint
+			if rr, err := dns.NewRR(fmt.Sprintf("%s %d IN %s %s", dnsName, ttl, endpoint.RecordType, target)); err == nil && rr != nil {
+				rrs = append(rrs, rr)
 			}
 		}
 	}
@@ -355,6 +372,59 @@ func (f *fileProvider) endpointToRRs(endpoint *endpoint.Endpoint) []dns.RR {
 	return rrs
 }
 
+// newSOA returns a SOA record for the zone named in header, with hostmaster@zone as the mailbox of the
+// administrator and conventional refresh, retry and expiry timers. The values are documented in the help of
+// the export command, which relies on them through WriteZone.
+func newSOA(header dns.RR_Header, ns string, serial uint32) *dns.SOA {
+	header.Rrtype = dns.TypeSOA
+	return &dns.SOA{
+		Hdr:     header,
+		Ns:      dns.Fqdn(ns),
+		Mbox:    "hostmaster." + header.Name,
+		Serial:  serial,
+		Refresh: 3600,
+		Retry:   600,
+		Expire:  86400,
+		Minttl:  header.Ttl,
+	}
+}
+
+// escapeTXT converts a TXT value into the escaped presentation format used by the dns library
+func escapeTXT(s string) string {
+	s = strings.ReplaceAll(s, `\`, `\\`)
+	return strings.ReplaceAll(s, `"`, `\"`)
+}
+
+// unescapeTXT converts a TXT string in presentation format into its plain value,
+// resolving both \X and \DDD escape sequences
+func unescapeTXT(s string) string {
+	if !strings.Contains(s, `\`) {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' || i+1 >= len(s) {
+			b.WriteByte(s[i])
+			continue
+		}
+		if i+3 < len(s) && isDigit(s[i+1]) && isDigit(s[i+2]) && isDigit(s[i+3]) {
+			if n, err := strconv.ParseUint(s[i+1:i+4], 10, 8); err == nil {
+				b.WriteByte(byte(n))
+				i += 3
+				continue
+			}
+		}
+		b.WriteByte(s[i+1])
+		i++
+	}
+	return b.String()
+}
+
+func isDigit(b byte) bool {
+	return b >= '0' && b <= '9'
+}
+
 // recordsMatch compares two DNS resource records for equality
 func (f *fileProvider) recordsMatch(rr1, rr2 dns.RR) bool {
 	if rr1 == nil || rr2 == nil {
@@ -426,67 +496,118 @@ func (f *fileProvider) writeZoneFile(records []dns.RR) error {
 	if err != nil {
 		return fmt.Errorf("failed to create zone file: %w", err)
 	}
-	defer file.Close()
 
 	writer := bufio.NewWriter(file)
-	defer writer.Flush()
 
 	// Write zone file header
 	fmt.Fprintf(writer, "; Zone file updated by dns-sync at %s\n", time.Now().Format(time.RFC3339))
 	fmt.Fprintf(writer, "; Backup saved as: %s\n\n", filepath.Base(backupPath))
 
-	// Group records by type for better organization
+	err = writeRecords(writer, records)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write zone file: %w", err)
+	}
+	return nil
+}
+
+// typeOrder is the order in which records are written to zone files, grouped by type. Other types
+// follow, ordered by their type code.
+var typeOrder = []uint16{
+	dns.TypeSOA,
+	dns.TypeNS,
+	dns.TypeA,
+	dns.TypeAAAA,
+	dns.TypeCNAME,
+	dns.TypeMX,
+	dns.TypeTXT,
+	dns.TypeSRV,
+	dns.TypePTR,
+}
+
+// writeRecords writes records in presentation format, grouped by type with a comment before each group
+func writeRecords(w io.Writer, records []dns.RR) error {
 	recordsByType := make(map[uint16][]dns.RR)
+	var otherTypes []uint16
 	for _, rr := range records {
 		rrtype := rr.Header().Rrtype
+		if _, exists := recordsByType[rrtype]; !exists && !slices.Contains(typeOrder, rrtype) {
+			otherTypes = append(otherTypes, rrtype)
+		}
 		recordsByType[rrtype] = append(recordsByType[rrtype], rr)
 	}
+	slices.Sort(otherTypes)
 
-	// Write records in a logical order
-	typeOrder := []uint16{
-		dns.TypeSOA,
-		dns.TypeNS,
-		dns.TypeA,
-		dns.TypeAAAA,
-		dns.TypeCNAME,
-		dns.TypeMX,
-		dns.TypeTXT,
-		dns.TypeSRV,
-		dns.TypePTR,
-	}
-
-	// Write records in order
-	for _, rrtype := range typeOrder {
-		if records, exists := recordsByType[rrtype]; exists {
-			if rrtype != dns.TypeSOA {
-				fmt.Fprintf(writer, "; %s records\n", dns.TypeToString[rrtype])
+	for _, rrtype := range append(slices.Clone(typeOrder), otherTypes...) {
+		records, exists := recordsByType[rrtype]
+		if !exists {
+			continue
+		}
+		if rrtype != dns.TypeSOA {
+			if _, err := fmt.Fprintf(w, "; %s records\n", dns.TypeToString[rrtype]); err != nil {
+				return err
 			}
-			for _, rr := range records {
-				fmt.Fprintf(writer, "%s\n", rr.String())
+		}
+		for _, rr := range records {
+			if _, err := fmt.Fprintf(w, "%s\n", rr.String()); err != nil {
+				return err
 			}
-			fmt.Fprintf(writer, "\n")
+		}
+		if _, err := fmt.Fprintf(w, "\n"); err != nil {
+			return err
 		}
 	}
+	return nil
+}
 
-	// Write any remaining record types not in the standard order
-	for rrtype, records := range recordsByType {
-		found := false
-		for _, standardType := range typeOrder {
-			if rrtype == standardType {
-				found = true
-				break
-			}
+// WriteZone writes records as a BIND zone file of the zone origin, starting with $ORIGIN and $TTL
+// directives. Records outside the zone are skipped, and a SOA record is generated when there is none so
+// that the file can be loaded by a name server, preceded by a comment saying so.
+func WriteZone(w io.Writer, origin string, records []*endpoint.Endpoint) error {
+	origin = dns.Fqdn(strings.ToLower(origin))
+
+	var rrs []dns.RR
+	var soa bool
+	var ns string
+	for _, record := range records {
+		if !dns.IsSubDomain(origin, dns.Fqdn(strings.ToLower(record.DNSName))) {
+			continue
 		}
-		if !found {
-			fmt.Fprintf(writer, "; %s records\n", dns.TypeToString[rrtype])
-			for _, rr := range records {
-				fmt.Fprintf(writer, "%s\n", rr.String())
+		for _, rr := range endpointToRRs(record) {
+			switch rr := rr.(type) {
+			case *dns.SOA:
+				soa = true
+			case *dns.NS:
+				if ns == "" && strings.EqualFold(rr.Hdr.Name, origin) {
+					ns = rr.Ns
+				}
 			}
-			fmt.Fprintf(writer, "\n")
+			rrs = append(rrs, rr)
+		}
+	}
+	if !soa {
+		if ns == "" {
+			ns = origin
 		}
+		serial, _ := strconv.ParseUint(time.Now().UTC().Format("2006010215"), 10, 32)
+		header := dns.RR_Header{Name: origin, Class: dns.ClassINET, Ttl: defaultTTL}
+		rrs = append([]dns.RR{newSOA(header, ns, uint32(serial))}, rrs...)
 	}
 
-	return nil
+	if _, err := fmt.Fprintf(w, "$ORIGIN %s\n$TTL %d\n\n", origin, defaultTTL); err != nil {
+		return err
+	}
+	if !soa {
+		if _, err := fmt.Fprintf(w, "; No SOA record was exported, this one was generated by dns-sync\n"); err != nil {
+			return err
+		}
+	}
+	return writeRecords(w, rrs)
 }
 
 // copyFile creates a backup copy of a file
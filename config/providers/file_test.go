@@ -3,9 +3,12 @@ package providers
 import (
 	"context"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/flanksource/dns-sync/config"
+	"github.com/miekg/dns"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"sigs.k8s.io/external-dns/endpoint"
@@ -304,3 +307,179 @@ $TTL 3600
 		assert.True(t, found, "Expected MX record missing after deletion: %s", target)
 	}
 }
+
+func TestFileProvider_TXTRoundTrip(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test-zone-*.txt")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Close()
+
+	provider := NewFileProvider(config.FileProviderConfig{Path: tmpfile.Name()}, endpoint.NewDomainFilter([]string{}))
+	value := `"heritage=external-dns,external-dns/owner=dns-sync" \ backslash`
+
+	ctx := context.Background()
+	err = provider.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("txt.example.com", "TXT", 300, value),
+			endpoint.NewEndpointWithTTL("txt.example.com", "TXT", 300, value),
+		},
+	})
+	require.NoError(t, err)
+
+	records, err := provider.Records(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1, "duplicate records should only be written once")
+	assert.Equal(t, []string{value}, []string(records[0].Targets))
+
+	err = provider.ApplyChanges(ctx, &plan.Changes{Delete: records})
+	require.NoError(t, err)
+	records, err = provider.Records(ctx)
+	require.NoError(t, err)
+	assert.Empty(t, records)
+}
+
+func TestFileProvider_TXTEscaping(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test-zone-*.txt")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	_, err = tmpfile.WriteString(`$ORIGIN example.com.
+quoted  300 IN TXT "say \"hi\" \\ \065\066"
+`)
+	require.NoError(t, err)
+	tmpfile.Close()
+
+	// Escape sequences of the zone file are resolved, both \X and \DDD
+	provider := NewFileProvider(config.FileProviderConfig{Path: tmpfile.Name()}, endpoint.NewDomainFilter([]string{}))
+	ctx := context.Background()
+	records, err := provider.Records(ctx)
+	require.NoError(t, err)
+	require.Len(t, records, 1)
+	assert.Equal(t, `say "hi" \ AB`, records[0].Targets[0])
+
+	// Values holding quotes, such as the ones of TXT registry ownership records, are escaped when written
+	value := `"heritage=external-dns,external-dns/owner=dns-sync"`
+	err = provider.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpointWithTTL("owner.example.com", "TXT", 300, value)}})
+	require.NoError(t, err)
+
+	data, err := os.ReadFile(tmpfile.Name())
+	require.NoError(t, err)
+	assert.Contains(t, string(data), `"\"heritage=external-dns,external-dns/owner=dns-sync\""`)
+	records, err = provider.Records(ctx)
+	require.NoError(t, err)
+	var values []string
+	for _, record := range records {
+		values = append(values, record.Targets[0])
+	}
+	assert.ElementsMatch(t, []string{`say "hi" \ AB`, value}, values)
+}
+
+func TestFileProvider_DuplicateCreates(t *testing.T) {
+	tmpfile, err := os.CreateTemp("", "test-zone-*.txt")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	_, err = tmpfile.WriteString("$ORIGIN example.com.\nwww 300 IN A 192.0.2.1\n")
+	require.NoError(t, err)
+	tmpfile.Close()
+
+	// A TXT registry creates one ownership record per endpoint, so the names holding several records
+	// of a type get the same ownership record several times. Records that exist are written once.
+	provider := NewFileProvider(config.FileProviderConfig{Path: tmpfile.Name()}, endpoint.NewDomainFilter([]string{}))
+	ctx := context.Background()
+	err = provider.ApplyChanges(ctx, &plan.Changes{
+		Create: []*endpoint.Endpoint{
+			endpoint.NewEndpointWithTTL("www.example.com", "A", 300, "192.0.2.1"),
+			endpoint.NewEndpointWithTTL("www.example.com", "A", 300, "192.0.2.2"),
+			endpoint.NewEndpointWithTTL("a-www.example.com", "TXT", 300, "owner"),
+			endpoint.NewEndpointWithTTL("a-www.example.com", "TXT", 300, "owner"),
+		},
+	})
+	require.NoError(t, err)
+
+	records, err := provider.Records(ctx)
+	require.NoError(t, err)
+	var got []string
+	for _, record := range records {
+		got = append(got, record.DNSName+" "+record.RecordType+" "+record.Targets[0])
+	}
+	assert.ElementsMatch(t, []string{
+		"www.example.com A 192.0.2.1",
+		"www.example.com A 192.0.2.2",
+		"a-www.example.com TXT owner",
+	}, got)
+}
+
+func TestWriteZone(t *testing.T) {
+	records := []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("www.example.com", "A", 60, "192.0.2.1", "192.0.2.2"),
+		endpoint.NewEndpointWithTTL("example.com", "NS", 3600, "ns1.example.net"),
+		endpoint.NewEndpointWithTTL("example.com", "MX", 300, "10 mail.example.com"),
+		endpoint.NewEndpointWithTTL("example.com", "CAA", 300, `0 issue "letsencrypt.org"`),
+		endpoint.NewEndpointWithTTL("txt.example.com", "TXT", 300, `v=spf1 "quoted" -all`),
+		endpoint.NewEndpointWithTTL("www.example.org", "A", 300, "192.0.2.3"),
+	}
+
+	tmpfile, err := os.CreateTemp("", "test-zone-*.txt")
+	require.NoError(t, err)
+	defer os.Remove(tmpfile.Name())
+	require.NoError(t, WriteZone(tmpfile, "Example.com.", records))
+	require.NoError(t, tmpfile.Close())
+
+	data, err := os.ReadFile(tmpfile.Name())
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "$ORIGIN example.com.\n$TTL 300\n")
+
+	// The file holds a complete SOA record followed by the records of the zone, those of other zones
+	// being skipped
+	zp := dns.NewZoneParser(strings.NewReader(string(data)), "", "")
+	rr, ok := zp.Next()
+	require.True(t, ok, zp.Err())
+	soa, isSOA := rr.(*dns.SOA)
+	require.True(t, isSOA)
+	assert.Equal(t, "ns1.example.net.", soa.Ns)
+	assert.Equal(t, "hostmaster.example.com.", soa.Mbox)
+	assert.NotZero(t, soa.Serial)
+
+	exported, err := NewFileProvider(config.FileProviderConfig{Path: tmpfile.Name()}, endpoint.NewDomainFilter([]string{})).Records(context.Background())
+	require.NoError(t, err)
+	var got []string
+	for _, record := range exported {
+		if record.RecordType != "SOA" {
+			got = append(got, record.String())
+		}
+	}
+	assert.ElementsMatch(t, []string{
+		"www.example.com 60 IN A  192.0.2.1 []",
+		"www.example.com 60 IN A  192.0.2.2 []",
+		"example.com 3600 IN NS  ns1.example.net []",
+		"example.com 300 IN MX  10 mail.example.com []",
+		`example.com 300 IN CAA  0 issue "letsencrypt.org" []`,
+		`txt.example.com 300 IN TXT  v=spf1 "quoted" -all []`,
+	}, got)
+}
+
+// flakyWriter fails its write at index fail, and accepts the others
+type flakyWriter struct {
+	writes, fail int
+}
+
+func (w *flakyWriter) Write(p []byte) (int, error) {
+	w.writes++
+	if w.writes-1 == w.fail {
+		return 0, errors.New("i/o error")
+	}
+	return len(p), nil
+}
+
+func TestWriteZone_WriteError(t *testing.T) {
+	records := []*endpoint.Endpoint{
+		endpoint.NewEndpointWithTTL("www.example.com", "A", 60, "192.0.2.1", "192.0.2.2"),
+		endpoint.NewEndpointWithTTL("example.com", "MX", 300, "10 mail.example.com"),
+	}
+	counter := &flakyWriter{fail: -1}
+	require.NoError(t, WriteZone(counter, testDomain, records))
+
+	// The zone is not reported as written whichever write fails
+	for fail := 0; fail < counter.writes; fail++ {
+		assert.Error(t, WriteZone(&flakyWriter{fail: fail}, testDomain, records), "failing write %d of %d", fail, counter.writes)
+	}
+}
@@ -0,0 +1,56 @@
+package providers
+
+import (
+	"context"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/pkg/errors"
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+// NewRateLimiter returns a token bucket limiter for the configured rate, or nil when calls are unlimited
+func NewRateLimiter(cfg config.RateLimitConfig) *rate.Limiter {
+	if cfg.RequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := cfg.Burst
+	if burst <= 0 {
+		burst = 1
+	}
+	return rate.NewLimiter(rate.Limit(cfg.RequestsPerSecond), burst)
+}
+
+// rateLimitedProvider waits for the limiter before every Records and ApplyChanges call
+type rateLimitedProvider struct {
+	provider.Provider
+	name    string
+	limiter *rate.Limiter
+}
+
+// NewRateLimitedProvider wraps a provider with a limiter, which should be shared by all providers using
+// the same account. The provider is returned as is when limiter is nil.
+func NewRateLimitedProvider(p provider.Provider, name string, limiter *rate.Limiter) provider.Provider {
+	if limiter == nil {
+		return p
+	}
+	return &rateLimitedProvider{Provider: p, name: name, limiter: limiter}
+}
+
+// Records returns the records of the provider
+func (p *rateLimitedProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	if err := p.limiter.Wait(ctx); err != nil {
+		return nil, errors.Wrapf(err, "rate limit of %s", p.name)
+	}
+	return p.Provider.Records(ctx)
+}
+
+// ApplyChanges applies changes to the provider
+func (p *rateLimitedProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	if err := p.limiter.Wait(ctx); err != nil {
+		return errors.Wrapf(err, "rate limit of %s", p.name)
+	}
+	return p.Provider.ApplyChanges(ctx, changes)
+}
@@ -0,0 +1,33 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRateLimitedProvider(t *testing.T) {
+	flaky := &flakyProvider{}
+	assert.Same(t, flaky, NewRateLimitedProvider(flaky, "flaky", NewRateLimiter(config.RateLimitConfig{})))
+
+	p := NewRateLimitedProvider(flaky, "flaky", NewRateLimiter(config.RateLimitConfig{RequestsPerSecond: 1000, Burst: 1}))
+	for range 3 {
+		_, err := p.Records(context.Background())
+		require.NoError(t, err)
+	}
+	assert.Equal(t, 3, flaky.calls)
+
+	// Waiting for a token gives up with the context
+	p = NewRateLimitedProvider(flaky, "flaky", NewRateLimiter(config.RateLimitConfig{RequestsPerSecond: 0.001}))
+	_, err := p.Records(context.Background())
+	require.NoError(t, err)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err = p.Records(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 4, flaky.calls)
+}
@@ -0,0 +1,28 @@
+package providers
+
+import (
+	"github.com/flanksource/dns-sync/config"
+	"sigs.k8s.io/external-dns/provider"
+	"sigs.k8s.io/external-dns/registry"
+)
+
+// NewTXTRegistry wraps a provider with a TXT ownership registry. Records created through the
+// registry are labelled with the owner ID, and only records owned by it are updated or deleted.
+//
+// The legacy TXT name format is kept alongside the new one, as the new format only encodes
+// A, AAAA, CNAME and NS records and would leave MX, SRV and TXT records without an owner.
+func NewTXTRegistry(p provider.Provider, cfg config.RegistryConfig, managedRecordTypes []string) (provider.Provider, error) {
+	return registry.NewTXTRegistry(
+		p,
+		cfg.Prefix,
+		cfg.Suffix,
+		cfg.OwnerID,
+		0, // records are listed once per sync, caching would only serve stale ownership
+		cfg.WildcardReplacement,
+		managedRecordTypes,
+		nil,
+		cfg.EncryptionKey != "",
+		[]byte(cfg.EncryptionKey),
+		false,
+	)
+}
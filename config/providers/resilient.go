@@ -0,0 +1,126 @@
+package providers
+
+import (
+	"context"
+	"math/rand/v2"
+	"time"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"github.com/sony/gobreaker"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+// NewCircuitBreaker returns a circuit breaker that opens after the configured number of consecutive
+// failures and lets a single probe through once the timeout has passed. It returns nil when the circuit
+// breaker is disabled. onStateChange is called whenever the state changes.
+func NewCircuitBreaker(name string, cfg config.CircuitBreakerConfig, onStateChange func(from, to gobreaker.State)) *gobreaker.CircuitBreaker {
+	if cfg.Failures <= 0 {
+		return nil
+	}
+	return gobreaker.NewCircuitBreaker(gobreaker.Settings{
+		Name:        name,
+		MaxRequests: 1,
+		Timeout:     cfg.Timeout,
+		ReadyToTrip: func(counts gobreaker.Counts) bool {
+			return counts.ConsecutiveFailures >= uint32(cfg.Failures)
+		},
+		IsSuccessful: func(err error) bool {
+			// Cancelled calls say nothing about the health of the provider
+			return err == nil || errors.Is(err, context.Canceled)
+		},
+		OnStateChange: func(name string, from, to gobreaker.State) {
+			if onStateChange != nil {
+				onStateChange(from, to)
+			}
+		},
+	})
+}
+
+// resilientProvider retries failed Records calls with exponential backoff and jitter, and stops calling
+// the provider while its circuit breaker is open. ApplyChanges is not retried: a failed call may have
+// applied part of the changes, which are planned again from the current records on the next sync.
+type resilientProvider struct {
+	provider.Provider
+	name    string
+	retry   config.RetryConfig
+	breaker *gobreaker.CircuitBreaker
+}
+
+// NewResilientProvider wraps a provider with retries and an optional circuit breaker, shared between
+// syncs so that consecutive failures are tracked across them
+func NewResilientProvider(p provider.Provider, name string, retry config.RetryConfig, breaker *gobreaker.CircuitBreaker) provider.Provider {
+	return &resilientProvider{Provider: p, name: name, retry: retry, breaker: breaker}
+}
+
+// Records returns the records of the provider
+func (p *resilientProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	var records []*endpoint.Endpoint
+	err := p.call(ctx, "records", p.retry.Attempts, func() (err error) {
+		records, err = p.Provider.Records(ctx)
+		return err
+	})
+	return records, err
+}
+
+// ApplyChanges applies changes to the provider
+func (p *resilientProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	return p.call(ctx, "apply", 1, func() error {
+		return p.Provider.ApplyChanges(ctx, changes)
+	})
+}
+
+// call runs fn through the circuit breaker, retrying it until it succeeds or the attempts are exhausted
+func (p *resilientProvider) call(ctx context.Context, operation string, attempts int, fn func() error) error {
+	if p.breaker == nil {
+		return p.withRetries(ctx, operation, attempts, fn)
+	}
+	_, err := p.breaker.Execute(func() (interface{}, error) {
+		return nil, p.withRetries(ctx, operation, attempts, fn)
+	})
+	if errors.Is(err, gobreaker.ErrOpenState) || errors.Is(err, gobreaker.ErrTooManyRequests) {
+		return errors.Wrapf(err, "provider %s is unhealthy", p.name)
+	}
+	return err
+}
+
+func (p *resilientProvider) withRetries(ctx context.Context, operation string, attempts int, fn func() error) error {
+	backoff := p.retry.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || attempt >= attempts || ctx.Err() != nil {
+			return err
+		}
+
+		delay := jitter(backoff)
+		log.WithFields(log.Fields{
+			"provider":  p.name,
+			"operation": operation,
+			"attempt":   attempt,
+			"attempts":  attempts,
+			"delay":     delay.Round(time.Millisecond).String(),
+		}).WithError(err).Warn("Provider call failed, retrying")
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(delay):
+		}
+
+		backoff *= 2
+		if p.retry.MaxBackoff > 0 && backoff > p.retry.MaxBackoff {
+			backoff = p.retry.MaxBackoff
+		}
+	}
+}
+
+// jitter returns a random delay between half and all of backoff, so that retries of providers that
+// failed at the same time are spread out
+func jitter(backoff time.Duration) time.Duration {
+	if backoff <= 0 {
+		return 0
+	}
+	return backoff/2 + rand.N(backoff/2+1)
+}
@@ -0,0 +1,90 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/pkg/errors"
+	"github.com/sony/gobreaker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+// flakyProvider fails the first failures calls made to it
+type flakyProvider struct {
+	provider.BaseProvider
+	failures int
+	calls    int
+}
+
+func (p *flakyProvider) Records(ctx context.Context) ([]*endpoint.Endpoint, error) {
+	p.calls++
+	if p.calls <= p.failures {
+		return nil, errors.New("temporary failure")
+	}
+	return []*endpoint.Endpoint{endpoint.NewEndpoint("www."+testDomain, endpoint.RecordTypeA, "192.0.2.1")}, nil
+}
+
+func (p *flakyProvider) ApplyChanges(ctx context.Context, changes *plan.Changes) error {
+	_, err := p.Records(ctx)
+	return err
+}
+
+func TestResilientProvider_Retry(t *testing.T) {
+	retry := config.RetryConfig{Attempts: 3, InitialBackoff: time.Millisecond, MaxBackoff: 2 * time.Millisecond}
+
+	flaky := &flakyProvider{failures: 2}
+	records, err := NewResilientProvider(flaky, "flaky", retry, nil).Records(context.Background())
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, 3, flaky.calls)
+
+	// Changes are applied once, as a failed call may have applied part of them
+	flaky = &flakyProvider{failures: 1}
+	err = NewResilientProvider(flaky, "flaky", retry, nil).ApplyChanges(context.Background(), &plan.Changes{})
+	assert.EqualError(t, err, "temporary failure")
+	assert.Equal(t, 1, flaky.calls)
+
+	// A cancelled context stops retrying
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	flaky = &flakyProvider{failures: 3}
+	_, err = NewResilientProvider(flaky, "flaky", retry, nil).Records(ctx)
+	assert.Error(t, err)
+	assert.Equal(t, 1, flaky.calls)
+}
+
+func TestResilientProvider_CircuitBreaker(t *testing.T) {
+	retry := config.RetryConfig{Attempts: 1}
+	assert.Nil(t, NewCircuitBreaker("disabled", config.CircuitBreakerConfig{}, nil))
+
+	var states []gobreaker.State
+	breaker := NewCircuitBreaker("flaky", config.CircuitBreakerConfig{Failures: 2, Timeout: 20 * time.Millisecond}, func(from, to gobreaker.State) {
+		states = append(states, to)
+	})
+	flaky := &flakyProvider{failures: 2}
+	p := NewResilientProvider(flaky, "flaky", retry, breaker)
+
+	for range 2 {
+		_, err := p.Records(context.Background())
+		assert.EqualError(t, err, "temporary failure")
+	}
+	assert.Equal(t, gobreaker.StateOpen, breaker.State())
+
+	// An open circuit fails fast without calling the provider
+	_, err := p.Records(context.Background())
+	assert.ErrorIs(t, err, gobreaker.ErrOpenState)
+	assert.Equal(t, 2, flaky.calls)
+
+	// Once the timeout has passed a successful probe closes the circuit
+	time.Sleep(30 * time.Millisecond)
+	_, err = p.Records(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, gobreaker.StateClosed, breaker.State())
+	assert.Equal(t, []gobreaker.State{gobreaker.StateOpen, gobreaker.StateHalfOpen, gobreaker.StateClosed}, states)
+}
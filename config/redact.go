@@ -0,0 +1,56 @@
+package config
+
+import (
+	"reflect"
+)
+
+// redacted replaces the value of secure fields that are set
+const redacted = "*****"
+
+// Redacted returns a copy of the configuration with the value of every field tagged secure:"yes" replaced,
+// at any depth, so that it can be logged or displayed
+func (c Config) Redacted() Config {
+	return redact(reflect.ValueOf(c)).Interface().(Config)
+}
+
+// redact returns a copy of value with its secure fields redacted. Maps and values of other kinds are
+// shared with the original, as they hold no secure fields.
+func redact(value reflect.Value) reflect.Value {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.New(value.Type().Elem())
+		copied.Elem().Set(redact(value.Elem()))
+		return copied
+	case reflect.Slice:
+		if value.IsNil() {
+			return value
+		}
+		copied := reflect.MakeSlice(value.Type(), value.Len(), value.Len())
+		for i := 0; i < value.Len(); i++ {
+			copied.Index(i).Set(redact(value.Index(i)))
+		}
+		return copied
+	case reflect.Struct:
+		// Copy the whole struct first, unexported fields can only be copied along with it
+		copied := reflect.New(value.Type()).Elem()
+		copied.Set(value)
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Tag.Get("secure") == "yes" && field.Type.Kind() == reflect.String {
+				if value.Field(i).String() != "" {
+					copied.Field(i).SetString(redacted)
+				}
+				continue
+			}
+			copied.Field(i).Set(redact(value.Field(i)))
+		}
+		return copied
+	}
+	return value
+}
@@ -0,0 +1,132 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// variablePattern matches ${NAME} and ${NAME:-default} references to environment variables, along with
+// $$ which escapes a literal $
+var variablePattern = regexp.MustCompile(`\$\$|\$\{([A-Za-z_][A-Za-z0-9_]*)(:-([^}]*))?\}`)
+
+// interpolate replaces references to environment variables in the scalar values of a YAML document, so that
+// values cannot change the structure of the document and comments are left alone. Mapping keys are not
+// interpolated. Variables without a default must be set, empty values are replaced by the default.
+func interpolate(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.DocumentNode, yaml.SequenceNode:
+		for _, child := range node.Content {
+			if err := interpolate(child); err != nil {
+				return err
+			}
+		}
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			if err := interpolate(node.Content[i]); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		var err error
+		value := variablePattern.ReplaceAllStringFunc(node.Value, func(match string) string {
+			if match == "$$" {
+				return "$"
+			}
+			groups := variablePattern.FindStringSubmatch(match)
+			name, hasDefault := groups[1], groups[2] != ""
+			if value := os.Getenv(name); value != "" {
+				return value
+			}
+			if _, set := os.LookupEnv(name); !set && !hasDefault && err == nil {
+				err = fmt.Errorf("line %d: environment variable %s is not set", node.Line, name)
+			}
+			return groups[3]
+		})
+		if err != nil {
+			return err
+		}
+		if value != node.Value {
+			node.Value = value
+			// Plain values are resolved again, so that numbers and booleans keep their type
+			if node.Style == 0 {
+				node.Tag = ""
+			}
+		}
+	}
+	// Aliases share the node of their anchor, which is interpolated once
+	return nil
+}
+
+// resolveSecret returns the value of a secure field, which can reference the secret rather than hold it:
+//
+//	env:NAME      the value of the environment variable NAME
+//	file:PATH     the contents of the file at PATH, without trailing newlines
+//	base64:DATA   the base64 decoded DATA
+func resolveSecret(value string) (string, error) {
+	kind, ref, ok := strings.Cut(value, ":")
+	if !ok {
+		return value, nil
+	}
+	switch kind {
+	case "env":
+		secret, set := os.LookupEnv(ref)
+		if !set {
+			return "", fmt.Errorf("environment variable %s is not set", ref)
+		}
+		return secret, nil
+	case "file":
+		data, err := os.ReadFile(ref)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	case "base64":
+		data, err := base64.StdEncoding.DecodeString(ref)
+		if err != nil {
+			return "", fmt.Errorf("invalid base64 secret: %w", err)
+		}
+		return string(data), nil
+	}
+	return value, nil
+}
+
+// resolveSecrets replaces the references held by all fields tagged secure:"yes" with the secrets they reference
+func (v *validator) resolveSecrets(p path, value reflect.Value) {
+	switch value.Kind() {
+	case reflect.Pointer:
+		if !value.IsNil() {
+			v.resolveSecrets(p, value.Elem())
+		}
+	case reflect.Slice:
+		for i := 0; i < value.Len(); i++ {
+			v.resolveSecrets(p.with(i), value.Index(i))
+		}
+	case reflect.Struct:
+		for i := 0; i < value.NumField(); i++ {
+			field := value.Type().Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			fp := p
+			if !strings.Contains(field.Tag.Get("yaml"), "inline") {
+				fp = p.with(yamlName(field))
+			}
+			if field.Tag.Get("secure") == "yes" && field.Type.Kind() == reflect.String {
+				secret, err := resolveSecret(value.Field(i).String())
+				if err != nil {
+					v.errorf(fp, "%v", err)
+					continue
+				}
+				value.Field(i).SetString(secret)
+				continue
+			}
+			v.resolveSecrets(fp, value.Field(i))
+		}
+	}
+}
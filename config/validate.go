@@ -0,0 +1,541 @@
+package config
+
+import (
+	"encoding/base64"
+	"fmt"
+	"net"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// recordTypes are the record types accepted in record filters and TTL policies
+var recordTypes = []string{"A", "AAAA", "CAA", "CNAME", "DS", "MX", "NAPTR", "NS", "PTR", "SOA", "SPF", "SRV", "TXT"}
+
+// unsupportedProviders are providers that can be configured but are not available in this build
+var unsupportedProviders = []string{"cloudfoundry", "webhook"}
+
+// FieldError is an invalid value in the configuration
+type FieldError struct {
+	// Path of the field, e.g. zones[0].targets[1].aws
+	Path string
+
+	// Line of the field in the configuration file, 0 when unknown
+	Line int
+
+	Message string
+}
+
+func (e FieldError) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("line %d: %s: %s", e.Line, e.Path, e.Message)
+	}
+	return fmt.Sprintf("%s: %s", e.Path, e.Message)
+}
+
+// ValidationError holds all invalid values found in a configuration
+type ValidationError struct {
+	Errors []FieldError
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		lines = append(lines, err.Error())
+	}
+	if len(lines) == 1 {
+		return lines[0]
+	}
+	return fmt.Sprintf("%d errors:\n  %s", len(lines), strings.Join(lines, "\n  "))
+}
+
+// Validate checks the configuration, returning a *ValidationError listing every invalid value
+func (c *Config) Validate() error {
+	return validate(c, nil)
+}
+
+// validate checks the configuration, using the YAML document it was parsed from, if any, to report lines
+func validate(c *Config, document *yaml.Node) error {
+	v := newValidator(document)
+
+	v.oneOf(path{"log_level"}, c.LogLevel, "", "debug", "info", "warn", "error")
+	v.oneOf(path{"log_format"}, c.LogFormat, "", "text", "json")
+	if c.MetricsAddress != "" {
+		if _, port, err := net.SplitHostPort(c.MetricsAddress); err != nil {
+			v.errorf(path{"metrics_address"}, "invalid address %q: %v", c.MetricsAddress, err)
+		} else {
+			v.port(path{"metrics_address"}, port)
+		}
+	}
+	v.nonNegative(path{"request_timeout"}, c.RequestTimeout)
+	v.validateSync(path{"sync"}, c.Sync)
+
+	names := make(map[string]int)
+	for i, zone := range c.Zones {
+		v.validateZone(path{"zones", i}, zone)
+		if zone == nil || zone.Name == "" {
+			continue
+		}
+		name := strings.TrimSuffix(strings.ToLower(zone.Name), ".")
+		if first, ok := names[name]; ok {
+			v.errorf(path{"zones", i, "name"}, "zone %s is already configured by zones[%d]", zone.Name, first)
+		} else {
+			names[name] = i
+		}
+	}
+
+	return v.err()
+}
+
+func (v *validator) validateSync(p path, s SyncConfig) {
+	if s.Interval < time.Second {
+		v.errorf(p.with("interval"), "must be at least 1s, got %s", s.Interval)
+	}
+	if s.EnableNotify || s.NotifyPort != 0 {
+		v.port(p.with("notify_port"), strconv.Itoa(s.NotifyPort))
+	}
+	v.validateNotify(p.with("notify"), s.Notify)
+	if s.Retry.Attempts < 0 {
+		v.errorf(p.with("retry", "attempts"), "must not be negative")
+	}
+	v.nonNegative(p.with("retry", "initial_backoff"), s.Retry.InitialBackoff)
+	v.nonNegative(p.with("retry", "max_backoff"), s.Retry.MaxBackoff)
+	if s.Retry.MaxBackoff > 0 && s.Retry.MaxBackoff < s.Retry.InitialBackoff {
+		v.errorf(p.with("retry", "max_backoff"), "must not be less than initial_backoff (%s)", s.Retry.InitialBackoff)
+	}
+	if s.CircuitBreaker.Failures < 0 {
+		v.errorf(p.with("circuit_breaker", "failures"), "must not be negative")
+	}
+	v.nonNegative(p.with("circuit_breaker", "timeout"), s.CircuitBreaker.Timeout)
+	v.validateRateLimit(p.with("rate_limit"), s.RateLimit)
+}
+
+func (v *validator) validateZone(p path, zone *ZoneConfig) {
+	if zone == nil {
+		v.errorf(p, "zone is empty")
+		return
+	}
+	if zone.Name == "" {
+		v.errorf(p.with("name"), "is required")
+	}
+	v.validateProvider(p.with("source"), zone.Source.ProviderConfig)
+	if len(zone.Targets) == 0 {
+		v.errorf(p.with("targets"), "at least one target is required")
+	}
+	v.validateRecordFilter(p.with("record_filter"), zone.RecordFilter)
+	v.policy(p.with("policy"), zone.Policy)
+	v.validateTTL(p.with("ttl"), zone.TTL)
+	v.validateSafety(p.with("safety"), zone.Safety)
+
+	for i, target := range zone.Targets {
+		tp := p.with("targets", i)
+		v.validateProvider(tp, target.ProviderConfig)
+		v.policy(tp.with("policy"), target.Policy)
+		v.validateTTL(tp.with("ttl"), target.TTL)
+		v.validateSafety(tp.with("safety"), target.Safety)
+		if target.RecordFilter != nil {
+			v.validateRecordFilter(tp.with("record_filter"), *target.RecordFilter)
+		}
+		if target.DomainFilter != nil {
+			if _, err := target.DomainFilter.Filter(); err != nil {
+				v.errorf(tp.with("domain_filter"), "%v", err)
+			}
+		}
+		if target.Rename != nil && target.Rename.To == "" {
+			v.errorf(tp.with("rename", "to"), "is required")
+		}
+		if target.Registry != nil {
+			v.validateRegistry(tp.with("registry"), *target.Registry)
+		}
+	}
+}
+
+// validateProvider checks that exactly one provider is configured, along with the fields it requires
+func (v *validator) validateProvider(p path, config ProviderConfig) {
+	var configured []string
+	value := reflect.ValueOf(config)
+	for i := 0; i < value.NumField(); i++ {
+		field := value.Type().Field(i)
+		if field.Name == "RateLimit" || value.Field(i).IsNil() {
+			continue
+		}
+		configured = append(configured, yamlName(field))
+	}
+
+	switch len(configured) {
+	case 0:
+		v.errorf(p, "no provider configured")
+	case 1:
+		if slices.Contains(unsupportedProviders, configured[0]) {
+			v.errorf(p.with(configured[0]), "provider %s is not supported", configured[0])
+		} else {
+			v.requiredFields(p.with(configured[0]), config)
+		}
+	default:
+		v.errorf(p, "exactly one provider must be configured, found %s", strings.Join(configured, ", "))
+	}
+
+	if config.RateLimit != nil {
+		v.validateRateLimit(p.with("rate_limit"), *config.RateLimit)
+	}
+}
+
+// requiredFields checks the fields required by the configured provider
+func (v *validator) requiredFields(p path, config ProviderConfig) {
+	required := func(name, value string) {
+		if value == "" {
+			v.errorf(p.with(name), "is required")
+		}
+	}
+
+	switch {
+	case config.Akamai != nil:
+		if config.Akamai.EdgercPath == "" {
+			required("service_consumer_domain", config.Akamai.ServiceConsumerDomain)
+			required("client_token", config.Akamai.ClientToken)
+			required("client_secret", config.Akamai.ClientSecret)
+			required("access_token", config.Akamai.AccessToken)
+		}
+	case config.OCI != nil:
+		if !config.OCI.AuthInstancePrincipal {
+			required("config_file", config.OCI.ConfigFile)
+		}
+	case config.PowerDNS != nil:
+		required("server", config.PowerDNS.Server)
+		required("api_key", config.PowerDNS.APIKey)
+	case config.IBMCloud != nil:
+		required("config_file", config.IBMCloud.ConfigFile)
+	case config.GoDaddy != nil:
+		required("api_key", config.GoDaddy.APIKey)
+		required("secret_key", config.GoDaddy.SecretKey)
+	case config.Exoscale != nil:
+		required("api_key", config.Exoscale.APIKey)
+		required("api_secret", config.Exoscale.APISecret)
+	case config.RFC2136 != nil:
+		if len(config.RFC2136.Host) == 0 {
+			v.errorf(p.with("host"), "is required")
+		}
+		if config.RFC2136.Port != 0 {
+			v.port(p.with("port"), strconv.Itoa(config.RFC2136.Port))
+		}
+		if config.RFC2136.TSIGKeyName != "" {
+			required("tsig_secret", config.RFC2136.TSIGSecret)
+		}
+	case config.AlibabaCloud != nil:
+		required("config_file", config.AlibabaCloud.ConfigFile)
+	case config.TencentCloud != nil:
+		required("config_file", config.TencentCloud.ConfigFile)
+	case config.TransIP != nil:
+		required("account_name", config.TransIP.AccountName)
+		required("private_key_file", config.TransIP.PrivateKeyFile)
+	case config.Pihole != nil:
+		required("server", config.Pihole.Server)
+	case config.Plural != nil:
+		required("cluster", config.Plural.Cluster)
+		required("provider", config.Plural.Provider)
+	case config.File != nil:
+		required("path", config.File.Path)
+	}
+}
+
+func (v *validator) validateRecordFilter(p path, filter RecordFilterConfig) {
+	v.recordTypes(p.with("include_types"), filter.IncludeTypes)
+	v.recordTypes(p.with("exclude_types"), filter.ExcludeTypes)
+	v.namePatterns(p.with("include_names"), filter.IncludeNames)
+	v.namePatterns(p.with("exclude_names"), filter.ExcludeNames)
+}
+
+func (v *validator) validateTTL(p path, ttl *TTLPolicyConfig) {
+	if ttl == nil {
+		return
+	}
+	if ttl.Min > 0 && ttl.Max > 0 && ttl.Min > ttl.Max {
+		v.errorf(p.with("min"), "must not be greater than max (%d)", ttl.Max)
+	}
+	types := make([]string, 0, len(ttl.Types))
+	for recordType := range ttl.Types {
+		types = append(types, recordType)
+	}
+	slices.Sort(types)
+	v.recordTypes(p.with("types"), types)
+}
+
+func (v *validator) validateSafety(p path, safety *SafetyConfig) {
+	if safety == nil {
+		return
+	}
+	if safety.MaxDeletes < 0 {
+		v.errorf(p.with("max_deletes"), "must not be negative")
+	}
+	if safety.MaxDeletePercent < 0 || safety.MaxDeletePercent > 100 {
+		v.errorf(p.with("max_delete_percent"), "must be between 0 and 100")
+	}
+	if safety.MinRecords < 0 {
+		v.errorf(p.with("min_records"), "must not be negative")
+	}
+}
+
+func (v *validator) validateRegistry(p path, registry RegistryConfig) {
+	if registry.OwnerID == "" {
+		v.errorf(p.with("owner_id"), "is required")
+	}
+	if registry.Prefix != "" && registry.Suffix != "" {
+		v.errorf(p.with("suffix"), "prefix and suffix are mutually exclusive")
+	}
+	if key := registry.EncryptionKey; key != "" && len(key) != 32 {
+		if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 32 {
+			v.errorf(p.with("encryption_key"), "must be 32 bytes, in plain text or base64")
+		}
+	}
+}
+
+func (v *validator) validateNotify(p path, notify NotifyConfig) {
+	for i, source := range notify.AllowedSources {
+		if _, err := parseNetwork(source); err != nil {
+			v.errorf(p.with("allowed_sources", i), "%v, expected a CIDR or an address", err)
+		}
+	}
+	if notify.TSIGKeyName != "" && notify.TSIGSecret == "" {
+		v.errorf(p.with("tsig_secret"), "is required with tsig_key_name")
+	}
+	if notify.TSIGSecret != "" {
+		if notify.TSIGKeyName == "" {
+			v.errorf(p.with("tsig_key_name"), "is required with tsig_secret")
+		}
+		if _, err := base64.StdEncoding.DecodeString(notify.TSIGSecret); err != nil {
+			v.errorf(p.with("tsig_secret"), "must be base64 encoded")
+		}
+	}
+	v.nonNegative(p.with("min_interval"), notify.MinInterval)
+}
+
+func (v *validator) validateRateLimit(p path, limit RateLimitConfig) {
+	if limit.RequestsPerSecond < 0 {
+		v.errorf(p.with("requests_per_second"), "must not be negative")
+	}
+	if limit.Burst < 0 {
+		v.errorf(p.with("burst"), "must not be negative")
+	}
+}
+
+// path is the location of a field in the configuration, made of YAML keys and sequence indexes
+type path []any
+
+func (p path) with(elements ...any) path {
+	return append(slices.Clip(p), elements...)
+}
+
+func (p path) String() string {
+	var b strings.Builder
+	for _, element := range p {
+		if index, ok := element.(int); ok {
+			fmt.Fprintf(&b, "[%d]", index)
+			continue
+		}
+		if b.Len() > 0 {
+			b.WriteByte('.')
+		}
+		b.WriteString(element.(string))
+	}
+	return b.String()
+}
+
+// validator collects the errors found in a configuration
+type validator struct {
+	root   *yaml.Node
+	errors []FieldError
+}
+
+// newValidator returns a validator reporting the lines of fields in document, which may be nil
+func newValidator(document *yaml.Node) *validator {
+	v := &validator{}
+	if document != nil && document.Kind == yaml.DocumentNode && len(document.Content) > 0 {
+		v.root = document.Content[0]
+	}
+	return v
+}
+
+// err returns a *ValidationError holding the errors found, or nil if there are none
+func (v *validator) err() error {
+	if len(v.errors) > 0 {
+		return &ValidationError{Errors: v.errors}
+	}
+	return nil
+}
+
+func (v *validator) errorf(p path, format string, args ...any) {
+	v.errors = append(v.errors, FieldError{Path: p.String(), Line: v.line(p), Message: fmt.Sprintf(format, args...)})
+}
+
+// line returns the line of the field at path, or of its closest parent present in the document
+func (v *validator) line(p path) int {
+	node := v.root
+	if node == nil {
+		return 0
+	}
+	line := node.Line
+	for _, element := range p {
+		var next *yaml.Node
+		switch element := element.(type) {
+		case int:
+			if node.Kind == yaml.SequenceNode && element < len(node.Content) {
+				next = node.Content[element]
+				line = next.Line
+			}
+		case string:
+			if node.Kind == yaml.MappingNode {
+				for i := 0; i+1 < len(node.Content); i += 2 {
+					if node.Content[i].Value == element {
+						next = node.Content[i+1]
+						line = node.Content[i].Line
+						break
+					}
+				}
+			}
+		}
+		if next == nil {
+			return line
+		}
+		node = next
+	}
+	return line
+}
+
+func (v *validator) oneOf(p path, value string, allowed ...string) {
+	if !slices.Contains(allowed, value) {
+		v.errorf(p, "must be one of %s, got %q", strings.Join(slices.DeleteFunc(slices.Clone(allowed), func(s string) bool { return s == "" }), ", "), value)
+	}
+}
+
+func (v *validator) nonNegative(p path, d time.Duration) {
+	if d < 0 {
+		v.errorf(p, "must not be negative")
+	}
+}
+
+func (v *validator) port(p path, port string) {
+	if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+		v.errorf(p, "invalid port %q", port)
+	}
+}
+
+func (v *validator) policy(p path, policy string) {
+	if _, ok := plan.Policies[policy]; policy != "" && !ok {
+		v.errorf(p, "unknown policy %q, must be one of sync, upsert-only, create-only", policy)
+	}
+}
+
+func (v *validator) recordTypes(p path, types []string) {
+	for _, recordType := range types {
+		if !slices.Contains(recordTypes, strings.ToUpper(recordType)) {
+			v.errorf(p, "unknown record type %q", recordType)
+		}
+	}
+}
+
+// namePatterns checks that every record name pattern compiles, as an invalid exclude pattern would
+// otherwise protect nothing
+func (v *validator) namePatterns(p path, patterns []string) {
+	for i, pattern := range patterns {
+		if _, err := CompileNamePattern(pattern); err != nil {
+			v.errorf(p.with(i), "invalid pattern %q: %v", pattern, err)
+		}
+	}
+}
+
+// knownFields reports the keys of the document that do not match a field of the type they are decoded into,
+// as they are usually misspelled options that would otherwise be silently ignored
+func (v *validator) knownFields(p path, node *yaml.Node, t reflect.Type) {
+	switch node.Kind {
+	case yaml.DocumentNode:
+		if len(node.Content) > 0 {
+			v.knownFields(p, node.Content[0], t)
+		}
+		return
+	case yaml.AliasNode:
+		v.knownFields(p, node.Alias, t)
+		return
+	}
+
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	switch t.Kind() {
+	case reflect.Struct:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		fields := yamlFields(t)
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+			if key.ShortTag() == "!!merge" {
+				v.knownMergedFields(p, value, t)
+				continue
+			}
+			field, ok := fields[key.Value]
+			if !ok {
+				v.errors = append(v.errors, FieldError{Path: p.with(key.Value).String(), Line: key.Line, Message: "unknown field"})
+				continue
+			}
+			v.knownFields(p.with(key.Value), value, field)
+		}
+	case reflect.Map:
+		if node.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			v.knownFields(p.with(node.Content[i].Value), node.Content[i+1], t.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		if node.Kind != yaml.SequenceNode {
+			return
+		}
+		for i, item := range node.Content {
+			v.knownFields(p.with(i), item, t.Elem())
+		}
+	}
+}
+
+// knownMergedFields checks the mappings merged into a mapping with <<, given as one mapping or a sequence of them
+func (v *validator) knownMergedFields(p path, node *yaml.Node, t reflect.Type) {
+	if node.Kind != yaml.SequenceNode {
+		v.knownFields(p, node, t)
+		return
+	}
+	for _, item := range node.Content {
+		v.knownFields(p, item, t)
+	}
+}
+
+// yamlFields returns the types of the fields of a struct by YAML key, including those of inlined structs
+func yamlFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() || field.Tag.Get("yaml") == "-" {
+			continue
+		}
+		if strings.Contains(field.Tag.Get("yaml"), "inline") {
+			for name, fieldType := range yamlFields(field.Type) {
+				fields[name] = fieldType
+			}
+			continue
+		}
+		fields[yamlName(field)] = field.Type
+	}
+	return fields
+}
+
+// yamlName returns the YAML key of a struct field
+func yamlName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("yaml"), ",")
+	if name == "" {
+		return strings.ToLower(field.Name)
+	}
+	return name
+}
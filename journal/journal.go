@@ -0,0 +1,211 @@
+// Package journal records every change applied to a target in an append-only, rotating JSON lines file
+package journal
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+const (
+	defaultMaxSizeMB = 100
+	defaultMaxFiles  = 10
+)
+
+// Entry records the changes applied to a target of a zone
+type Entry struct {
+	Time       time.Time     `json:"time"`
+	Zone       string        `json:"zone"`
+	Target     string        `json:"target"`
+	ConfigHash string        `json:"config_hash"`
+	Changes    *plan.Changes `json:"changes"`
+}
+
+// Journal appends entries to a journal file, rotating it once it grows beyond the maximum size.
+// It is safe for concurrent use.
+type Journal struct {
+	mu       sync.Mutex
+	path     string
+	maxSize  int64
+	maxFiles int
+}
+
+// New returns a journal writing to the configured path, or nil when the journal is disabled
+func New(cfg config.JournalConfig) *Journal {
+	if cfg.Path == "" {
+		return nil
+	}
+	if cfg.MaxSizeMB <= 0 {
+		cfg.MaxSizeMB = defaultMaxSizeMB
+	}
+	if cfg.MaxFiles <= 0 {
+		cfg.MaxFiles = defaultMaxFiles
+	}
+	return &Journal{
+		path:     cfg.Path,
+		maxSize:  int64(cfg.MaxSizeMB) * 1024 * 1024,
+		maxFiles: cfg.MaxFiles,
+	}
+}
+
+// Append writes an entry to the journal
+func (j *Journal) Append(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode journal entry")
+	}
+	line = append(line, '\n')
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if info, err := os.Stat(j.path); err == nil && info.Size() > 0 && info.Size()+int64(len(line)) > j.maxSize {
+		if err := j.rotate(); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(j.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err != nil {
+		return errors.Wrap(err, "failed to open journal")
+	}
+	if _, err := f.Write(line); err != nil {
+		_ = f.Close()
+		return errors.Wrap(err, "failed to write journal")
+	}
+	return errors.Wrap(f.Close(), "failed to write journal")
+}
+
+// rotate moves the journal to path.1, shifting older files up and removing the oldest.
+// Callers must hold the lock.
+func (j *Journal) rotate() error {
+	if err := os.Remove(rotatedPath(j.path, j.maxFiles)); err != nil && !os.IsNotExist(err) {
+		return errors.Wrap(err, "failed to remove oldest journal")
+	}
+	for i := j.maxFiles - 1; i >= 0; i-- {
+		if err := os.Rename(rotatedPath(j.path, i), rotatedPath(j.path, i+1)); err != nil && !os.IsNotExist(err) {
+			return errors.Wrap(err, "failed to rotate journal")
+		}
+	}
+	return nil
+}
+
+// rotatedPath returns the path of the journal file rotated n times, n = 0 being the current file
+func rotatedPath(path string, n int) string {
+	if n == 0 {
+		return path
+	}
+	return fmt.Sprintf("%s.%d", path, n)
+}
+
+// Query selects journal entries, empty fields match everything
+type Query struct {
+	Zone  string
+	Name  string
+	Since time.Time
+	Until time.Time
+}
+
+// matches returns the entry restricted to the changes selected by the query, or nil if nothing matches
+func (q Query) matches(entry Entry) *Entry {
+	if q.Zone != "" && normalize(entry.Zone) != normalize(q.Zone) {
+		return nil
+	}
+	if !q.Since.IsZero() && entry.Time.Before(q.Since) {
+		return nil
+	}
+	if !q.Until.IsZero() && entry.Time.After(q.Until) {
+		return nil
+	}
+	if q.Name == "" || entry.Changes == nil {
+		return &entry
+	}
+
+	filter := func(records []*endpoint.Endpoint) []*endpoint.Endpoint {
+		var filtered []*endpoint.Endpoint
+		for _, record := range records {
+			if normalize(record.DNSName) == normalize(q.Name) {
+				filtered = append(filtered, record)
+			}
+		}
+		return filtered
+	}
+	changes := &plan.Changes{
+		Create:    filter(entry.Changes.Create),
+		UpdateOld: filter(entry.Changes.UpdateOld),
+		UpdateNew: filter(entry.Changes.UpdateNew),
+		Delete:    filter(entry.Changes.Delete),
+	}
+	if !changes.HasChanges() {
+		return nil
+	}
+	entry.Changes = changes
+	return &entry
+}
+
+// Read returns the entries of the journal at path and its rotated files matching the query, oldest first
+func Read(path string, query Query) ([]Entry, error) {
+	var files []string
+	for i := 0; ; i++ {
+		if _, err := os.Stat(rotatedPath(path, i)); err != nil {
+			// The current file only goes missing when no entry has been written yet
+			if i == 0 && os.IsNotExist(err) {
+				continue
+			}
+			break
+		}
+		files = append(files, rotatedPath(path, i))
+	}
+	if len(files) == 0 {
+		return nil, errors.Errorf("journal %s does not exist", path)
+	}
+	slices.Reverse(files)
+
+	var entries []Entry
+	for _, file := range files {
+		if err := readFile(file, func(entry Entry) {
+			if matched := query.matches(entry); matched != nil {
+				entries = append(entries, *matched)
+			}
+		}); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func readFile(path string, fn func(Entry)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open journal")
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(strings.TrimSpace(scanner.Text())) == 0 {
+			continue
+		}
+		var entry Entry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return errors.Wrapf(err, "invalid journal entry at %s:%d", path, line)
+		}
+		fn(entry)
+	}
+	return errors.Wrapf(scanner.Err(), "failed to read %s", path)
+}
+
+func normalize(name string) string {
+	return strings.TrimSuffix(strings.ToLower(name), ".")
+}
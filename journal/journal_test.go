@@ -0,0 +1,86 @@
+package journal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+func TestJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	assert.Nil(t, New(config.JournalConfig{}))
+
+	j := New(config.JournalConfig{Path: path})
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	for i, zone := range []string{"example.com", "example.org", "example.com"} {
+		require.NoError(t, j.Append(Entry{
+			Time:   start.Add(time.Duration(i) * time.Hour),
+			Zone:   zone,
+			Target: "File{target.bind}",
+			Changes: &plan.Changes{
+				Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www."+zone, "A", "192.0.2.1")},
+				Delete: []*endpoint.Endpoint{endpoint.NewEndpoint("old."+zone, "A", "192.0.2.2")},
+			},
+		}))
+	}
+
+	entries, err := Read(path, Query{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+
+	entries, err = Read(path, Query{Zone: "Example.com."})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	entries, err = Read(path, Query{Zone: "example.com", Since: start.Add(30 * time.Minute)})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Equal(t, start.Add(2*time.Hour), entries[0].Time)
+
+	entries, err = Read(path, Query{Until: start.Add(90 * time.Minute)})
+	require.NoError(t, err)
+	assert.Len(t, entries, 2)
+
+	// Only the changes to the queried name are returned
+	entries, err = Read(path, Query{Name: "old.example.org"})
+	require.NoError(t, err)
+	require.Len(t, entries, 1)
+	assert.Empty(t, entries[0].Changes.Create)
+	assert.Len(t, entries[0].Changes.Delete, 1)
+
+	_, err = Read(filepath.Join(t.TempDir(), "missing.jsonl"), Query{})
+	assert.Error(t, err)
+}
+
+func TestJournalRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+	j := New(config.JournalConfig{Path: path, MaxFiles: 2})
+	j.maxSize = 200
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, j.Append(Entry{
+			Time:    time.Unix(int64(i), 0).UTC(),
+			Zone:    "example.com",
+			Changes: &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", "A", "192.0.2.1")}},
+		}))
+	}
+
+	assert.FileExists(t, path+".1")
+	assert.FileExists(t, path+".2")
+	_, err := os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+
+	// The oldest entries have been removed, the remaining ones are read oldest first
+	entries, err := Read(path, Query{})
+	require.NoError(t, err)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, time.Unix(2, 0).UTC(), entries[0].Time)
+	assert.Equal(t, time.Unix(4, 0).UTC(), entries[2].Time)
+}
@@ -0,0 +1,106 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/flanksource/dns-sync/sync"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	log "github.com/sirupsen/logrus"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take once the server is stopped
+const shutdownTimeout = 5 * time.Second
+
+// Server exposes the health, readiness, sync status and metrics of a Synchronizer over HTTP
+type Server struct {
+	syncer   *sync.Synchronizer
+	server   *http.Server
+	listener net.Listener
+}
+
+// New creates a server listening on addr for the given synchronizer
+func New(addr string, syncer *sync.Synchronizer) *Server {
+	s := &Server{syncer: syncer}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/health", s.health)
+	mux.HandleFunc("/ready", s.ready)
+	mux.HandleFunc("/status", s.status)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	s.server = &http.Server{
+		Addr:              addr,
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	return s
+}
+
+// Handler returns the HTTP handler serving all endpoints
+func (s *Server) Handler() http.Handler {
+	return s.server.Handler
+}
+
+// Listen binds the address of the server, so that an address in use fails startup rather than the
+// server once it runs in the background
+func (s *Server) Listen() error {
+	l, err := net.Listen("tcp", s.server.Addr)
+	if err != nil {
+		return err
+	}
+	s.listener = l
+	return nil
+}
+
+// Start serves HTTP requests until the context is cancelled, binding the address first unless Listen did
+func (s *Server) Start(ctx context.Context) error {
+	if s.listener == nil {
+		if err := s.Listen(); err != nil {
+			return err
+		}
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.WithField("address", s.listener.Addr().String()).Info("Serving health checks, status and metrics")
+		errCh <- s.server.Serve(s.listener)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+	return s.server.Shutdown(shutdownCtx)
+}
+
+// health reports that the process is alive
+func (s *Server) health(w http.ResponseWriter, _ *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// ready reports whether all zones have been synced successfully at least once
+func (s *Server) ready(w http.ResponseWriter, _ *http.Request) {
+	if !s.syncer.Ready() {
+		http.Error(w, "initial sync not completed", http.StatusServiceUnavailable)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// status returns the sync status of all zones as JSON
+func (s *Server) status(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(s.syncer.GetStatus()); err != nil {
+		log.WithError(err).Error("Failed to encode status")
+	}
+}
@@ -0,0 +1,61 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/flanksource/dns-sync/sync"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServer(t *testing.T) {
+	syncer := sync.NewSynchronizer(config.Config{})
+	handler := New(":0", syncer).Handler()
+
+	get := func(path string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		return w
+	}
+
+	assert.Equal(t, http.StatusOK, get("/health").Code)
+	assert.Equal(t, http.StatusOK, get("/metrics").Code)
+	assert.Equal(t, http.StatusServiceUnavailable, get("/ready").Code)
+
+	_, err := syncer.Once(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, get("/ready").Code)
+
+	w := get("/status")
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	var status []sync.ZoneStatus
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Empty(t, status)
+}
+
+func TestServerListen(t *testing.T) {
+	syncer := sync.NewSynchronizer(config.Config{})
+	s := New("127.0.0.1:0", syncer)
+	require.NoError(t, s.Listen())
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- s.Start(ctx) }()
+
+	resp, err := http.Get("http://" + s.listener.Addr().String() + "/health")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	// An address in use is reported when binding, not once serving in the background
+	assert.Error(t, New(s.listener.Addr().String(), syncer).Listen())
+
+	cancel()
+	assert.NoError(t, <-done)
+}
@@ -0,0 +1,159 @@
+// Package snapshot stores copies of the records of a target taken before changes are applied to it,
+// so that the target can be rolled back to any of them
+package snapshot
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+const (
+	defaultKeep = 20
+
+	// idFormat sorts snapshot ids chronologically
+	idFormat = "20060102T150405.000000000Z"
+)
+
+// Snapshot holds the records of a target at a point in time
+type Snapshot struct {
+	ID      string               `json:"id"`
+	Time    time.Time            `json:"time"`
+	Zone    string               `json:"zone"`
+	Index   int                  `json:"index"`
+	Target  string               `json:"target"`
+	Records []*endpoint.Endpoint `json:"records"`
+}
+
+// Store keeps the most recent snapshots of every zone and target in a directory, as
+// <dir>/<zone>/<target index>/<id>.json
+type Store struct {
+	dir  string
+	keep int
+}
+
+// New returns a store in the configured directory, or nil when snapshots are disabled
+func New(cfg config.SnapshotConfig) *Store {
+	if cfg.Dir == "" {
+		return nil
+	}
+	if cfg.Keep <= 0 {
+		cfg.Keep = defaultKeep
+	}
+	return &Store{dir: cfg.Dir, keep: cfg.Keep}
+}
+
+// Save stores the records of the target at index of a zone, removing the oldest snapshots beyond
+// the number to keep. It returns the id of the new snapshot.
+func (s *Store) Save(zone string, index int, target string, records []*endpoint.Endpoint) (string, error) {
+	now := time.Now().UTC()
+	snapshot := Snapshot{
+		ID:      now.Format(idFormat),
+		Time:    now,
+		Zone:    zone,
+		Index:   index,
+		Target:  target,
+		Records: records,
+	}
+
+	dir := s.targetDir(zone, index)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return "", errors.Wrap(err, "failed to create snapshot directory")
+	}
+	data, err := json.Marshal(snapshot)
+	if err != nil {
+		return "", errors.Wrap(err, "failed to encode snapshot")
+	}
+
+	// Write to a temporary file first, so that a partial snapshot is never listed
+	path := filepath.Join(dir, snapshot.ID+".json")
+	if err := os.WriteFile(path+".tmp", data, 0600); err != nil {
+		return "", errors.Wrap(err, "failed to write snapshot")
+	}
+	if err := os.Rename(path+".tmp", path); err != nil {
+		return "", errors.Wrap(err, "failed to write snapshot")
+	}
+
+	ids, err := s.ids(zone, index)
+	if err != nil {
+		return "", err
+	}
+	for len(ids) > s.keep {
+		if err := os.Remove(filepath.Join(dir, ids[0]+".json")); err != nil {
+			return "", errors.Wrap(err, "failed to remove old snapshot")
+		}
+		ids = ids[1:]
+	}
+	return snapshot.ID, nil
+}
+
+// List returns the snapshots of the target at index of a zone, oldest first
+func (s *Store) List(zone string, index int) ([]*Snapshot, error) {
+	ids, err := s.ids(zone, index)
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := make([]*Snapshot, 0, len(ids))
+	for _, id := range ids {
+		snapshot, err := s.Load(zone, index, id)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+	return snapshots, nil
+}
+
+// Load returns a snapshot of the target at index of a zone
+func (s *Store) Load(zone string, index int, id string) (*Snapshot, error) {
+	if id == "" || strings.ContainsAny(id, `/\`) {
+		return nil, errors.Errorf("invalid snapshot id %q", id)
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.targetDir(zone, index), id+".json"))
+	if os.IsNotExist(err) {
+		return nil, errors.Errorf("snapshot %s of target %d of zone %s does not exist", id, index, zone)
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to read snapshot")
+	}
+
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse snapshot %s", id)
+	}
+	return &snapshot, nil
+}
+
+// ids returns the ids of the snapshots of a target, oldest first
+func (s *Store) ids(zone string, index int) ([]string, error) {
+	entries, err := os.ReadDir(s.targetDir(zone, index))
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, errors.Wrap(err, "failed to list snapshots")
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if id, ok := strings.CutSuffix(entry.Name(), ".json"); ok && !entry.IsDir() {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *Store) targetDir(zone string, index int) string {
+	zone = strings.TrimSuffix(strings.ToLower(zone), ".")
+	zone = strings.NewReplacer("/", "_", `\`, "_").Replace(zone)
+	return filepath.Join(s.dir, zone, strconv.Itoa(index))
+}
@@ -0,0 +1,42 @@
+package snapshot
+
+import (
+	"testing"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestStore(t *testing.T) {
+	assert.Nil(t, New(config.SnapshotConfig{}))
+
+	store := New(config.SnapshotConfig{Dir: t.TempDir(), Keep: 2})
+	var ids []string
+	for _, ip := range []string{"192.0.2.1", "192.0.2.2", "192.0.2.3"} {
+		id, err := store.Save("Example.com.", 1, "File{target.bind}", []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", "A", ip)})
+		require.NoError(t, err)
+		ids = append(ids, id)
+	}
+
+	// Only the most recent snapshots are kept
+	snapshots, err := store.List("example.com", 1)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, ids[1:], []string{snapshots[0].ID, snapshots[1].ID})
+
+	snapshot, err := store.Load("example.com", 1, ids[2])
+	require.NoError(t, err)
+	assert.Equal(t, "File{target.bind}", snapshot.Target)
+	assert.Equal(t, "192.0.2.3", snapshot.Records[0].Targets[0])
+
+	_, err = store.Load("example.com", 1, ids[0])
+	assert.ErrorContains(t, err, "does not exist")
+	_, err = store.Load("example.com", 1, "../../other")
+	assert.Error(t, err)
+
+	snapshots, err = store.List("example.com", 0)
+	require.NoError(t, err)
+	assert.Empty(t, snapshots)
+}
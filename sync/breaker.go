@@ -0,0 +1,94 @@
+package sync
+
+import (
+	"sync"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/flanksource/dns-sync/config/providers"
+	log "github.com/sirupsen/logrus"
+	"github.com/sony/gobreaker"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+// breakerStore keeps a circuit breaker for every zone of a provider account, shared by the sources and
+// targets using the account for the zone, so that failures are counted across syncs. Breakers are kept per
+// zone because providers configured from the environment share one account for all of their zones, and a
+// failing zone must not stop the others. It is safe for concurrent use.
+type breakerStore struct {
+	mu       sync.Mutex
+	config   config.CircuitBreakerConfig
+	breakers map[string]*zoneBreaker
+}
+
+type zoneBreaker struct {
+	*gobreaker.CircuitBreaker
+	zone, account string
+}
+
+func newBreakerStore(cfg config.CircuitBreakerConfig) *breakerStore {
+	return &breakerStore{config: cfg, breakers: make(map[string]*zoneBreaker)}
+}
+
+func breakerKey(zone, account string) string {
+	return account + "/" + zone
+}
+
+// get returns the circuit breaker of a zone of a provider account, creating it on first use.
+// It returns nil when circuit breakers are disabled.
+func (b *breakerStore) get(zone, account string) *gobreaker.CircuitBreaker {
+	if b.config.Failures <= 0 {
+		return nil
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	key := breakerKey(zone, account)
+	if breaker, ok := b.breakers[key]; ok {
+		return breaker.CircuitBreaker
+	}
+	breaker := providers.NewCircuitBreaker(key, b.config, func(from, to gobreaker.State) {
+		log.WithFields(log.Fields{"zone": zone, "account": account, "from": from.String(), "to": to.String()}).Warn("Circuit breaker changed state")
+		circuitState.WithLabelValues(zone, account).Set(float64(to))
+	})
+	circuitState.WithLabelValues(zone, account).Set(float64(gobreaker.StateClosed))
+	b.breakers[key] = &zoneBreaker{CircuitBreaker: breaker, zone: zone, account: account}
+	return breaker
+}
+
+// state returns the state of the circuit breaker of a zone of a provider account, empty when it has none
+func (b *breakerStore) state(zone, account string) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if breaker, ok := b.breakers[breakerKey(zone, account)]; ok {
+		return breaker.State().String()
+	}
+	return ""
+}
+
+// prune drops the circuit breakers of the zones and accounts that are no longer used by any source or target
+func (b *breakerStore) prune(zones []*config.ZoneConfig) {
+	used := make(map[string]bool)
+	for _, zone := range zones {
+		used[breakerKey(zone.Name, zone.Source.ProviderConfig.Account())] = true
+		for _, target := range zone.Targets {
+			used[breakerKey(targetZoneName(zone, target), target.ProviderConfig.Account())] = true
+		}
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for key, breaker := range b.breakers {
+		if !used[key] {
+			delete(b.breakers, key)
+			circuitState.DeleteLabelValues(breaker.zone, breaker.account)
+		}
+	}
+}
+
+// resilient wraps a provider with retries and the circuit breaker of its account for a zone
+func (s *Synchronizer) resilient(p provider.Provider, providerConfig config.ProviderConfig, zone string) provider.Provider {
+	return providers.NewResilientProvider(p, providerConfig.String(), s.config.Sync.Retry, s.breakers.get(zone, providerConfig.Account()))
+}
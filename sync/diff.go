@@ -0,0 +1,140 @@
+package sync
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// ZoneDiff holds the differences between the records of a zone held by two providers
+type ZoneDiff struct {
+	Zone  string
+	Left  *ZoneRecords
+	Right *ZoneRecords
+
+	// Changes are those a sync would apply to the right provider for it to hold the records of the left one:
+	// Create holds the records only held by left and Delete those only held by right, one per target
+	Changes *plan.Changes
+}
+
+// Differs returns true when the providers hold different records
+func (d *ZoneDiff) Differs() bool {
+	return len(d.Changes.Create) > 0 || len(d.Changes.UpdateNew) > 0 || len(d.Changes.Delete) > 0
+}
+
+// Diff compares the records of a zone held by two providers, selected as for Records, without changing
+// either of them. The source of a configured zone is compared to one of its targets as a sync would: the
+// desired records are transformed and filtered for the target, and the target is listed through its TXT
+// registry, so that the changes are exactly those a sync would apply. Other providers are compared by value,
+// with the record filter of the zone applied when the zone is configured and the records of a renamed target
+// compared under the names of the left zone.
+func (s *Synchronizer) Diff(ctx context.Context, zone, left, right string) (*ZoneDiff, error) {
+	zoneConfig := s.findZoneConfig(zone)
+	if match := targetPattern.FindStringSubmatch(right); match != nil && left == "source" && zoneConfig != nil {
+		index, _ := strconv.Atoi(match[1])
+		return s.diffTarget(ctx, zoneConfig, index)
+	}
+
+	leftRecords, err := s.diffRecords(ctx, zone, left)
+	if err != nil {
+		return nil, err
+	}
+	rightRecords, err := s.diffRecords(ctx, zone, right)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := comparableRecords(leftRecords.Records, leftRecords.Zone, leftRecords.Zone)
+	current := comparableRecords(rightRecords.Records, rightRecords.Zone, leftRecords.Zone)
+	if zoneConfig != nil {
+		if desired, err = s.filterRecords(desired, zoneConfig.RecordFilter); err != nil {
+			return nil, err
+		}
+		if current, err = s.filterRecords(current, zoneConfig.RecordFilter); err != nil {
+			return nil, err
+		}
+	}
+
+	var types []string
+	for _, record := range append(slices.Clone(desired), current...) {
+		if !slices.Contains(types, record.RecordType) {
+			types = append(types, record.RecordType)
+		}
+	}
+
+	p := Calculate(&plan.Plan{Desired: desired, Current: current, ManagedRecords: types})
+	return &ZoneDiff{Zone: zone, Left: leftRecords, Right: rightRecords, Changes: p.Changes}, nil
+}
+
+// diffTarget compares the source of a zone to the target at index using the plan of a sync, the records of
+// both sides are those of the target zone
+func (s *Synchronizer) diffTarget(ctx context.Context, zoneConfig *config.ZoneConfig, index int) (*ZoneDiff, error) {
+	if index >= len(zoneConfig.Targets) {
+		return nil, errors.Errorf("zone %s has no target %d", zoneConfig.Name, index)
+	}
+	targetConfig := zoneConfig.Targets[index]
+
+	desired, err := s.sourceRecords(ctx, zoneConfig)
+	if err != nil {
+		return nil, err
+	}
+	target, err := s.connectTarget(ctx, zoneConfig, index)
+	if err != nil {
+		return nil, err
+	}
+	p, err := s.planTarget(zoneConfig, targetConfig, target, desired)
+	if err != nil {
+		return nil, err
+	}
+
+	zone := targetZoneName(zoneConfig, targetConfig)
+	return &ZoneDiff{
+		Zone:    zoneConfig.Name,
+		Left:    &ZoneRecords{Zone: zone, Provider: zoneConfig.Source.ProviderConfig.String(), Records: p.Desired},
+		Right:   &ZoneRecords{Zone: zone, Provider: target.name, Records: target.current},
+		Changes: p.Changes,
+	}, nil
+}
+
+// diffRecords fetches the records of a zone as for Records, except that the targets of a configured zone are
+// listed through their TXT registry so that ownership records are not reported as differences
+func (s *Synchronizer) diffRecords(ctx context.Context, zone, from string) (*ZoneRecords, error) {
+	zoneConfig := s.findZoneConfig(zone)
+	match := targetPattern.FindStringSubmatch(from)
+	if match == nil || zoneConfig == nil {
+		return s.Records(ctx, zone, from)
+	}
+
+	index, _ := strconv.Atoi(match[1])
+	if index >= len(zoneConfig.Targets) {
+		return nil, errors.Errorf("zone %s has no target %d", zoneConfig.Name, index)
+	}
+	target, err := s.connectTarget(ctx, zoneConfig, index)
+	if err != nil {
+		return nil, err
+	}
+	return &ZoneRecords{Zone: targetZoneName(zoneConfig, zoneConfig.Targets[index]), Provider: target.name, Records: target.current}, nil
+}
+
+// comparableRecords returns a copy of records with one record per target, without labels and with
+// normalized names moved from zone from into zone to, so that the records listed by different providers compare by value
+func comparableRecords(records []*endpoint.Endpoint, from, to string) []*endpoint.Endpoint {
+	var result []*endpoint.Endpoint
+	for _, record := range records {
+		for _, target := range record.Targets {
+			result = append(result, &endpoint.Endpoint{
+				DNSName:       normalizeName(replaceSuffix(record.DNSName, from, to)),
+				RecordType:    record.RecordType,
+				Targets:       endpoint.Targets{target},
+				RecordTTL:     record.RecordTTL,
+				SetIdentifier: record.SetIdentifier,
+			})
+		}
+	}
+	return result
+}
@@ -0,0 +1,111 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestDiff(t *testing.T) {
+	source, _ := os.CreateTemp("", "zones.bind")
+	target, _ := os.CreateTemp("", "target.bind")
+	_ = os.WriteFile(source.Name(), []byte(`$ORIGIN example.com.
+www  300 IN A 192.0.2.1
+www  300 IN A 192.0.2.2
+api  300 IN A 192.0.2.3
+mail 300 IN MX 10 mx.example.com.
+`), 0600)
+	_ = os.WriteFile(target.Name(), []byte(`$ORIGIN example.net.
+WWW  300 IN A 192.0.2.2
+www  300 IN A 192.0.2.1
+api  300 IN A 192.0.2.4
+old  300 IN A 192.0.2.5
+mail 300 IN MX 10 mx.example.com.
+`), 0600)
+
+	s := NewSynchronizer(config.Config{
+		Zones: []*config.ZoneConfig{
+			{
+				Name:         "example.com",
+				RecordFilter: config.RecordFilterConfig{IncludeTypes: []string{"A"}},
+				Source: config.SourceConfig{
+					ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+				},
+				Targets: []config.TargetConfig{
+					{
+						ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}},
+						Rename:         &config.RenameConfig{To: "example.net"},
+					},
+				},
+			},
+		},
+	})
+
+	// The source is compared to the target as a sync would, under the names of the renamed target with the
+	// MX records filtered: names that only differ in case are replaced by a sync as well
+	diff, err := s.Diff(context.Background(), "example.com", "source", "target[0]")
+	require.NoError(t, err)
+	assert.True(t, diff.Differs())
+	assert.ElementsMatch(t, []string{"www.example.net 300 IN A  192.0.2.2 []", "api.example.net 300 IN A  192.0.2.3 []"}, recordStrings(diff.Changes.Create))
+	assert.ElementsMatch(t, []string{"WWW.example.net 300 IN A  192.0.2.2 []", "api.example.net 300 IN A  192.0.2.4 []", "old.example.net 300 IN A  192.0.2.5 []"},
+		recordStrings(diff.Changes.Delete))
+
+	// Other providers are compared by value under the names of the left zone
+	diff, err = s.Diff(context.Background(), "example.com", "target[0]", "source")
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []string{"api.example.net 300 IN A  192.0.2.4 []", "old.example.net 300 IN A  192.0.2.5 []"}, recordStrings(diff.Changes.Create))
+	assert.ElementsMatch(t, []string{"api.example.net 300 IN A  192.0.2.3 []"}, recordStrings(diff.Changes.Delete))
+
+	diff, err = s.Diff(context.Background(), "example.com", "source", "file: {path: "+source.Name()+"}")
+	require.NoError(t, err)
+	assert.False(t, diff.Differs())
+}
+
+func recordStrings(records []*endpoint.Endpoint) []string {
+	result := []string{}
+	for _, record := range records {
+		result = append(result, record.String())
+	}
+	return result
+}
+
+func TestDiffAfterSync(t *testing.T) {
+	source, _ := os.CreateTemp("", "zones.bind")
+	target, _ := os.CreateTemp("", "target.bind")
+	_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+	_ = os.WriteFile(target.Name(), []byte("manual.dr.example.net. 300 IN A 10.0.0.1\n"), 0600)
+
+	cfg := config.Config{
+		Zones: []*config.ZoneConfig{
+			{
+				Name: "example.com",
+				Source: config.SourceConfig{
+					ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+				},
+				Targets: []config.TargetConfig{
+					{
+						ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}},
+						Registry:       &config.RegistryConfig{OwnerID: "dns-sync"},
+						Rename:         &config.RenameConfig{To: "dr.example.net", RewriteTargets: true},
+						Rewrite:        &config.RewriteConfig{Addresses: map[string]string{"192.0.2.0/24": "198.51.100.0/24"}},
+						TTL:            &config.TTLPolicyConfig{TTL: 600},
+					},
+				},
+			},
+		},
+	}
+
+	test(t, cfg, 11, 0, 0)
+
+	// The renamed, rewritten records with their new TTL match the source, and neither the ownership
+	// records nor the unowned manual record are reported
+	diff, err := NewSynchronizer(cfg).Diff(context.Background(), "example.com", "source", "target[0]")
+	require.NoError(t, err)
+	assert.False(t, diff.Differs(), "created %v, deleted %v", recordStrings(diff.Changes.Create), recordStrings(diff.Changes.Delete))
+	assert.Equal(t, "dr.example.net", diff.Right.Zone)
+}
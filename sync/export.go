@@ -0,0 +1,87 @@
+package sync
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/flanksource/dns-sync/config/providers"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+// ZoneRecords holds the records of a zone as listed by its source or one of its targets
+type ZoneRecords struct {
+	// Zone is the name of the zone on the provider, which differs from the configured name on renamed targets
+	Zone     string
+	Provider string
+	Records  []*endpoint.Endpoint
+}
+
+// targetPattern matches references to a target of a zone by index, such as target[1]
+var targetPattern = regexp.MustCompile(`^target\[(\d+)\]$`)
+
+// Records fetches the records of a zone from the provider selected with from: "source", "target[N]" for the
+// target at index N, or a provider configuration given inline as YAML, in which case the zone need not be
+// configured. The records are returned as listed by the provider, sorted by name and type: filters,
+// transformations and TXT registries are not applied, only records outside the zone are dropped.
+func (s *Synchronizer) Records(ctx context.Context, zone, from string) (*ZoneRecords, error) {
+	zoneConfig := s.findZoneConfig(zone)
+	if zoneConfig == nil && (from == "source" || targetPattern.MatchString(from)) {
+		return nil, errors.Errorf("zone %s is not configured", zone)
+	}
+
+	result := &ZoneRecords{Zone: zone}
+	var p provider.Provider
+	var err error
+	if match := targetPattern.FindStringSubmatch(from); match != nil {
+		index, _ := strconv.Atoi(match[1])
+		if index >= len(zoneConfig.Targets) {
+			return nil, errors.Errorf("zone %s has no target %d", zoneConfig.Name, index)
+		}
+		targetConfig := zoneConfig.Targets[index]
+		result.Zone, result.Provider = targetZoneName(zoneConfig, targetConfig), targetConfig.ProviderConfig.String()
+		p, _, err = s.targetProvider(ctx, zoneConfig, index)
+	} else if from == "source" {
+		result.Zone, result.Provider = zoneConfig.Name, zoneConfig.Source.ProviderConfig.String()
+		p, err = s.sourceProvider(ctx, zoneConfig)
+	} else {
+		var providerConfig *config.ProviderConfig
+		if providerConfig, err = config.ParseProvider("provider", from); err != nil {
+			return nil, errors.Wrapf(err, "invalid provider %q, expected source, target[N] or a provider configuration", from)
+		}
+		result.Provider = providerConfig.String()
+		domainFilter := endpoint.NewDomainFilter([]string{strings.TrimSuffix(zone, ".")})
+		if p, err = providers.GetProvider(ctx, *providerConfig, domainFilter, provider.NewZoneIDFilter(nil), s.config.Sync.DryRun); err != nil {
+			return nil, errors.Wrapf(err, "failed to get provider %s", result.Provider)
+		}
+		p = s.rateLimited(p, *providerConfig)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	records, err := p.Records(ctx)
+	if err != nil {
+		providerErrors.WithLabelValues(zone, result.Provider, "records").Inc()
+		return nil, errors.Wrapf(err, "failed to fetch records from provider %s for zone %s", result.Provider, zone)
+	}
+	domainFilter := endpoint.NewDomainFilter([]string{strings.TrimSuffix(result.Zone, ".")})
+	for _, record := range records {
+		if domainFilter.Match(record.DNSName) {
+			result.Records = append(result.Records, record)
+		}
+	}
+	sort.SliceStable(result.Records, func(i, j int) bool {
+		a, b := result.Records[i], result.Records[j]
+		if a.DNSName != b.DNSName {
+			return a.DNSName < b.DNSName
+		}
+		return a.RecordType < b.RecordType
+	})
+	return result, nil
+}
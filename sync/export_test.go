@@ -0,0 +1,69 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecords(t *testing.T) {
+	source, _ := os.CreateTemp("", "zones.bind")
+	target, _ := os.CreateTemp("", "target.bind")
+	_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+	_ = os.WriteFile(target.Name(), []byte("b.example.net. 300 IN A 10.0.0.2\na.example.net. 300 IN A 10.0.0.1\nother.example.org. 300 IN A 10.0.0.3\n"), 0600)
+
+	s := NewSynchronizer(config.Config{
+		Zones: []*config.ZoneConfig{
+			{
+				Name:         "example.com",
+				RecordFilter: config.RecordFilterConfig{IncludeTypes: []string{"A"}},
+				Source: config.SourceConfig{
+					ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+				},
+				Targets: []config.TargetConfig{
+					{
+						ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}},
+						Rename:         &config.RenameConfig{To: "example.net"},
+					},
+				},
+			},
+		},
+	})
+
+	// The record filter of the zone is not applied
+	records, err := s.Records(context.Background(), "example.com.", "source")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", records.Zone)
+	types := map[string]bool{}
+	for _, record := range records.Records {
+		types[record.RecordType] = true
+	}
+	assert.True(t, types["MX"])
+	assert.True(t, types["SRV"])
+
+	// Target records are those of the renamed zone, sorted by name
+	records, err = s.Records(context.Background(), "example.com", "target[0]")
+	require.NoError(t, err)
+	assert.Equal(t, "example.net", records.Zone)
+	require.Len(t, records.Records, 2)
+	assert.Equal(t, "a.example.net", records.Records[0].DNSName)
+	assert.Equal(t, "b.example.net", records.Records[1].DNSName)
+
+	_, err = s.Records(context.Background(), "example.com", "target[1]")
+	assert.EqualError(t, err, "zone example.com has no target 1")
+	_, err = s.Records(context.Background(), "example.com", "destination")
+	assert.ErrorContains(t, err, `invalid provider "destination", expected source, target[N] or a provider configuration`)
+	_, err = s.Records(context.Background(), "example.org", "source")
+	assert.EqualError(t, err, "zone example.org is not configured")
+
+	// Providers given inline can list zones that are not configured
+	records, err = s.Records(context.Background(), "example.org", "file: {path: "+target.Name()+"}")
+	require.NoError(t, err)
+	assert.Equal(t, "example.org", records.Zone)
+	require.Len(t, records.Records, 1)
+	assert.Equal(t, "other.example.org", records.Records[0].DNSName)
+}
@@ -0,0 +1,155 @@
+package sync
+
+import (
+	"slices"
+	"strings"
+	"sync"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// patterns caches compiled record filter name patterns by pattern
+var patterns sync.Map
+
+// filterRecords filters records based on the configured filter. It fails when a name pattern doesn't
+// compile, which the validation of the configuration prevents.
+func (s *Synchronizer) filterRecords(records []*endpoint.Endpoint, filter config.RecordFilterConfig) ([]*endpoint.Endpoint, error) {
+	includeNames, err := compilePatterns(filter.IncludeNames)
+	if err != nil {
+		return nil, err
+	}
+	excludeNames, err := compilePatterns(filter.ExcludeNames)
+	if err != nil {
+		return nil, err
+	}
+
+	var filtered []*endpoint.Endpoint
+
+	for _, record := range records {
+		// Check if record type should be included
+		if len(filter.IncludeTypes) > 0 {
+			included := false
+			for _, includeType := range filter.IncludeTypes {
+				if record.RecordType == includeType {
+					included = true
+					break
+				}
+			}
+			if !included {
+				continue
+			}
+		}
+
+		// Check if record type should be excluded
+		excluded := false
+		for _, excludeType := range filter.ExcludeTypes {
+			if record.RecordType == excludeType {
+				excluded = true
+				break
+			}
+		}
+		if excluded {
+			continue
+		}
+
+		// Check if record name should be included
+		if len(includeNames) > 0 {
+			included := false
+			for _, includeName := range includeNames {
+				if includeName.Match(record.DNSName) {
+					included = true
+					break
+				}
+			}
+			if !included {
+				continue
+			}
+		}
+
+		// Check if record name should be excluded
+		excluded = false
+		for _, excludeName := range excludeNames {
+			if excludeName.Match(record.DNSName) {
+				excluded = true
+				break
+			}
+		}
+		if excluded {
+			continue
+		}
+
+		filtered = append(filtered, record)
+	}
+
+	return filtered, nil
+}
+
+// filterTargetRecords filters the records of a target with the zone record filter. Its name patterns are
+// written against the names of the source zone, so the records of a renamed target are matched under the
+// names they were renamed from.
+func (s *Synchronizer) filterTargetRecords(records []*endpoint.Endpoint, zoneConfig *config.ZoneConfig, targetConfig config.TargetConfig) ([]*endpoint.Endpoint, error) {
+	if targetConfig.Rename == nil {
+		return s.filterRecords(records, zoneConfig.RecordFilter)
+	}
+
+	from := renameFrom(zoneConfig.Name, *targetConfig.Rename)
+	originals := make(map[*endpoint.Endpoint]*endpoint.Endpoint, len(records))
+	renamed := make([]*endpoint.Endpoint, 0, len(records))
+	for _, record := range records {
+		source := *record
+		source.DNSName = replaceSuffix(record.DNSName, targetConfig.Rename.To, from)
+		originals[&source] = record
+		renamed = append(renamed, &source)
+	}
+
+	filtered, err := s.filterRecords(renamed, zoneConfig.RecordFilter)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]*endpoint.Endpoint, 0, len(filtered))
+	for _, record := range filtered {
+		result = append(result, originals[record])
+	}
+	return result, nil
+}
+
+// compilePatterns returns the compiled record filter name patterns, compiling each pattern once
+func compilePatterns(names []string) ([]*config.NamePattern, error) {
+	compiled := make([]*config.NamePattern, 0, len(names))
+	for _, name := range names {
+		if pattern, ok := patterns.Load(name); ok {
+			compiled = append(compiled, pattern.(*config.NamePattern))
+			continue
+		}
+		pattern, err := config.CompileNamePattern(name)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid record filter pattern %q", name)
+		}
+		patterns.Store(name, pattern)
+		compiled = append(compiled, pattern)
+	}
+	return compiled, nil
+}
+
+// normalizeName lower cases a DNS name and removes its trailing dot
+func normalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// managedRecordTypes returns the record types managed on a target, the types included by both the zone
+// and the target record filter
+func managedRecordTypes(zoneConfig *config.ZoneConfig, targetConfig config.TargetConfig) []string {
+	if targetConfig.RecordFilter == nil || len(targetConfig.RecordFilter.IncludeTypes) == 0 {
+		return zoneConfig.RecordFilter.IncludeTypes
+	}
+
+	var types []string
+	for _, recordType := range zoneConfig.RecordFilter.IncludeTypes {
+		if slices.Contains(targetConfig.RecordFilter.IncludeTypes, recordType) {
+			types = append(types, recordType)
+		}
+	}
+	return types
+}
@@ -0,0 +1,38 @@
+package sync
+
+import (
+	"testing"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestFilterRecords(t *testing.T) {
+	records := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", "A", "192.0.2.1"),
+		endpoint.NewEndpoint("v1.api.example.com", "A", "192.0.2.2"),
+		endpoint.NewEndpoint("temp.api.example.com", "A", "192.0.2.3"),
+		endpoint.NewEndpoint("v1.api.example.com", "TXT", "v=1"),
+		endpoint.NewEndpoint("mail.example.com", "A", "192.0.2.4"),
+	}
+
+	s := NewSynchronizer(config.Config{})
+	filtered, err := s.filterRecords(records, config.RecordFilterConfig{
+		IncludeTypes: []string{"A"},
+		IncludeNames: []string{"*.api.example.com", "www.example.com"},
+		ExcludeNames: []string{"temp.*"},
+	})
+	require.NoError(t, err)
+
+	var names []string
+	for _, record := range filtered {
+		names = append(names, record.DNSName)
+	}
+	assert.Equal(t, []string{"www.example.com", "v1.api.example.com"}, names)
+
+	// An invalid exclude pattern fails rather than excluding nothing
+	_, err = s.filterRecords(records, config.RecordFilterConfig{ExcludeNames: []string{"regex:("}})
+	assert.ErrorContains(t, err, `invalid record filter pattern "regex:("`)
+}
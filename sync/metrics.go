@@ -0,0 +1,56 @@
+package sync
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const metricsNamespace = "dns_sync"
+
+var (
+	syncDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "sync_duration_seconds",
+		Help:      "Time taken to sync a zone to a target",
+		Buckets:   prometheus.ExponentialBuckets(0.05, 2, 12),
+	}, []string{"zone", "target"})
+
+	planChanges = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "plan_changes_total",
+		Help:      "Number of changes produced by the planner, by action (create, update, delete)",
+	}, []string{"zone", "target", "action"})
+
+	recordsFetched = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "records_fetched",
+		Help:      "Number of records fetched from a provider during the last sync, before filtering",
+	}, []string{"zone", "provider"})
+
+	providerErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "provider_errors_total",
+		Help:      "Number of failed provider calls, by operation (init, records, apply)",
+	}, []string{"zone", "provider", "operation"})
+
+	plansRefused = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "plans_refused_total",
+		Help:      "Number of plans that were not applied as they violated a safety threshold",
+	}, []string{"zone", "target"})
+
+	circuitState = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "circuit_breaker_state",
+		Help:      "State of the circuit breaker of a zone of a provider account (0 = closed, 1 = half-open, 2 = open)",
+	}, []string{"zone", "account"})
+
+	lastSuccess = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Unix timestamp of the last successful sync of a zone to a target",
+	}, []string{"zone", "target"})
+)
+
+func init() {
+	prometheus.MustRegister(syncDuration, planChanges, recordsFetched, providerErrors, plansRefused, circuitState, lastSuccess)
+}
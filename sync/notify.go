@@ -0,0 +1,145 @@
+package sync
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/miekg/dns"
+	log "github.com/sirupsen/logrus"
+)
+
+// NotifyServer listens for DNS NOTIFY messages (RFC 1996) over UDP and TCP and hands
+// the notified zone to a callback
+type NotifyServer struct {
+	addr   string
+	notify func(zone string) bool
+
+	// allowed holds the networks NOTIFY messages are accepted from, any when empty
+	allowed []netip.Prefix
+
+	// tsig holds the secret of the TSIG key NOTIFY messages must be signed with, by key name, nil when
+	// messages need not be signed
+	tsig map[string]string
+}
+
+// NewNotifyServer creates a NOTIFY listener on addr, accepting the messages allowed by cfg. notify is called
+// with the zone name of every NOTIFY accepted and returns false if the zone is not managed by this server.
+func NewNotifyServer(addr string, cfg config.NotifyConfig, notify func(zone string) bool) (*NotifyServer, error) {
+	allowed, err := cfg.AllowedNetworks()
+	if err != nil {
+		return nil, err
+	}
+	n := &NotifyServer{
+		addr:    addr,
+		notify:  notify,
+		allowed: allowed,
+	}
+	if cfg.TSIGKeyName != "" {
+		n.tsig = map[string]string{dns.CanonicalName(cfg.TSIGKeyName): cfg.TSIGSecret}
+	}
+	return n, nil
+}
+
+// Start serves NOTIFY messages until the context is cancelled or a listener fails
+func (n *NotifyServer) Start(ctx context.Context) error {
+	pc, err := net.ListenPacket("udp", n.addr)
+	if err != nil {
+		return err
+	}
+	defer pc.Close()
+
+	l, err := net.Listen("tcp", n.addr)
+	if err != nil {
+		return err
+	}
+	defer l.Close()
+
+	servers := []*dns.Server{
+		{PacketConn: pc, Handler: n, TsigSecret: n.tsig},
+		{Listener: l, Handler: n, TsigSecret: n.tsig},
+	}
+
+	errCh := make(chan error, len(servers))
+	for _, server := range servers {
+		go func(server *dns.Server) {
+			errCh <- server.ActivateAndServe()
+		}(server)
+	}
+	log.WithField("address", n.addr).Info("Listening for DNS NOTIFY")
+	if len(n.allowed) == 0 && n.tsig == nil {
+		log.Warn("Accepting DNS NOTIFY from any source, set sync.notify.allowed_sources or a TSIG key to restrict them")
+	}
+
+	select {
+	case <-ctx.Done():
+	case err = <-errCh:
+	}
+
+	for _, server := range servers {
+		_ = server.Shutdown()
+	}
+	return err
+}
+
+// ServeDNS implements dns.Handler, answering NOTIFY messages and rejecting everything else
+func (n *NotifyServer) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+
+	switch {
+	case r.Opcode != dns.OpcodeNotify:
+		m.SetRcode(r, dns.RcodeNotImplemented)
+	case !n.allowedSource(w.RemoteAddr()):
+		log.WithField("remote", w.RemoteAddr().String()).Warn("Refusing NOTIFY from a source that is not allowed")
+		m.SetRcode(r, dns.RcodeRefused)
+	case n.tsig != nil && !n.signed(w, r):
+		log.WithField("remote", w.RemoteAddr().String()).Warn("Refusing NOTIFY without a valid TSIG signature")
+		m.SetRcode(r, dns.RcodeRefused)
+	case len(r.Question) != 1 || r.Question[0].Qtype != dns.TypeSOA:
+		m.SetRcode(r, dns.RcodeFormatError)
+	case !n.notify(r.Question[0].Name):
+		log.WithFields(log.Fields{"zone": r.Question[0].Name, "remote": w.RemoteAddr().String()}).Warn("Ignoring NOTIFY for unmanaged zone")
+		m.SetRcode(r, dns.RcodeRefused)
+	default:
+		log.WithFields(log.Fields{"zone": r.Question[0].Name, "remote": w.RemoteAddr().String()}).Info("Received NOTIFY")
+		m.Authoritative = true
+	}
+
+	// Answers to signed messages are signed with the same key
+	if tsig := r.IsTsig(); tsig != nil && n.tsig != nil && w.TsigStatus() == nil {
+		m.SetTsig(tsig.Hdr.Name, tsig.Algorithm, tsig.Fudge, time.Now().Unix())
+	}
+	if err := w.WriteMsg(m); err != nil {
+		log.WithField("remote", w.RemoteAddr().String()).WithError(err).Error("Failed to answer NOTIFY")
+	}
+}
+
+// allowedSource returns true if NOTIFY messages are accepted from the address
+func (n *NotifyServer) allowedSource(addr net.Addr) bool {
+	if len(n.allowed) == 0 {
+		return true
+	}
+	addrPort, err := netip.ParseAddrPort(addr.String())
+	if err != nil {
+		return false
+	}
+	for _, network := range n.allowed {
+		if network.Contains(addrPort.Addr().Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// signed returns true if the message was signed with the TSIG key of the server
+func (n *NotifyServer) signed(w dns.ResponseWriter, r *dns.Msg) bool {
+	tsig := r.IsTsig()
+	if tsig == nil || w.TsigStatus() != nil {
+		return false
+	}
+	_, ok := n.tsig[dns.CanonicalName(tsig.Hdr.Name)]
+	return ok
+}
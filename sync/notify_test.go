@@ -0,0 +1,118 @@
+package sync
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/miekg/dns"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// serveNotify serves NOTIFY messages with n on a local UDP port, returning its address
+func serveNotify(t *testing.T, n *NotifyServer) string {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	started := make(chan struct{})
+	server := &dns.Server{PacketConn: pc, Handler: n, TsigSecret: n.tsig, NotifyStartedFunc: func() { close(started) }}
+	go func() { _ = server.ActivateAndServe() }()
+	t.Cleanup(func() { _ = server.Shutdown() })
+	<-started
+	return pc.LocalAddr().String()
+}
+
+func TestNotifyServer(t *testing.T) {
+	var notified []string
+	n, err := NewNotifyServer("", config.NotifyConfig{}, func(zone string) bool {
+		notified = append(notified, zone)
+		return zone == "example.com."
+	})
+	require.NoError(t, err)
+	addr := serveNotify(t, n)
+
+	exchange := func(m *dns.Msg) *dns.Msg {
+		r, err := dns.Exchange(m, addr)
+		require.NoError(t, err)
+		return r
+	}
+
+	m := new(dns.Msg)
+	m.SetNotify("example.com.")
+	r := exchange(m)
+	assert.Equal(t, dns.RcodeSuccess, r.Rcode)
+	assert.Equal(t, dns.OpcodeNotify, r.Opcode)
+	assert.True(t, r.Authoritative)
+
+	m.SetNotify("other.com.")
+	assert.Equal(t, dns.RcodeRefused, exchange(m).Rcode)
+
+	m = new(dns.Msg)
+	m.SetQuestion("example.com.", dns.TypeA)
+	assert.Equal(t, dns.RcodeNotImplemented, exchange(m).Rcode)
+
+	assert.Equal(t, []string{"example.com.", "other.com."}, notified)
+}
+
+func TestNotifyServerRestrictions(t *testing.T) {
+	const secret = "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+	var notified int
+	notify := func(zone string) bool {
+		notified++
+		return true
+	}
+	send := func(addr, key, secret string) int {
+		m := new(dns.Msg)
+		m.SetNotify("example.com.")
+		client := &dns.Client{}
+		if key != "" {
+			m.SetTsig(key, dns.HmacSHA256, 300, time.Now().Unix())
+			client.TsigSecret = map[string]string{key: secret}
+		}
+		r, _, err := client.Exchange(m, addr)
+		require.NoError(t, err)
+		return r.Rcode
+	}
+
+	// Sources outside the allowed networks are refused
+	n, err := NewNotifyServer("", config.NotifyConfig{AllowedSources: []string{"192.0.2.0/24", "2001:db8::/32"}}, notify)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RcodeRefused, send(serveNotify(t, n), "", ""))
+
+	n, err = NewNotifyServer("", config.NotifyConfig{AllowedSources: []string{"192.0.2.0/24", "127.0.0.1"}}, notify)
+	require.NoError(t, err)
+	assert.Equal(t, dns.RcodeSuccess, send(serveNotify(t, n), "", ""))
+
+	// Messages that are not signed with the TSIG key are refused
+	n, err = NewNotifyServer("", config.NotifyConfig{TSIGKeyName: "notify-key", TSIGSecret: secret}, notify)
+	require.NoError(t, err)
+	addr := serveNotify(t, n)
+	assert.Equal(t, dns.RcodeRefused, send(addr, "", ""))
+	assert.Equal(t, dns.RcodeRefused, send(addr, "notify-key.", "b3RoZXItc2VjcmV0"))
+	assert.Equal(t, dns.RcodeRefused, send(addr, "other-key.", secret))
+	assert.Equal(t, dns.RcodeSuccess, send(addr, "notify-key.", secret))
+
+	assert.Equal(t, 2, notified)
+
+	_, err = NewNotifyServer("", config.NotifyConfig{AllowedSources: []string{"192.0.2.0/33"}}, notify)
+	assert.Error(t, err)
+}
+
+func TestNotifyZone(t *testing.T) {
+	s := NewSynchronizer(config.Config{
+		Sync:  config.SyncConfig{Notify: config.NotifyConfig{MinInterval: time.Hour}},
+		Zones: []*config.ZoneConfig{{Name: "Example.com"}, {Name: "example.net"}},
+	})
+
+	assert.True(t, s.notifyZone("example.com."))
+	assert.False(t, s.notifyZone("other.com."))
+	assert.Equal(t, "Example.com", <-s.notify)
+
+	// Further NOTIFY messages for the zone are acknowledged without queueing a sync until min_interval has passed
+	assert.True(t, s.notifyZone("example.com"))
+	assert.True(t, s.notifyZone("example.net"))
+	assert.Equal(t, "example.net", <-s.notify)
+	assert.Empty(t, s.notify)
+}
@@ -222,7 +222,7 @@ func Calculate(p *plan.Plan) *plan.Plan {
 	// filter out updates this external dns does not have ownership claim over
 	if p.OwnerID != "" {
 		changes.Delete = endpoint.FilterEndpointsByOwnerID(p.OwnerID, changes.Delete)
-		changes.Delete = endpoint.RemoveDuplicates(changes.Delete)
+		changes.Delete = removeDuplicates(changes.Delete)
 		changes.UpdateOld = endpoint.FilterEndpointsByOwnerID(p.OwnerID, changes.UpdateOld)
 		changes.UpdateNew = endpoint.FilterEndpointsByOwnerID(p.OwnerID, changes.UpdateNew)
 	}
@@ -263,6 +263,20 @@ func filterRecordsForPlan(records []*endpoint.Endpoint, domainFilter endpoint.Ma
 	return filtered
 }
 
+// removeDuplicates removes identical records. Unlike endpoint.RemoveDuplicates it compares the
+// whole record, as the planner works on individual records rather than record sets.
+func removeDuplicates(endpoints []*endpoint.Endpoint) []*endpoint.Endpoint {
+	seen := make(map[string]bool, len(endpoints))
+	var result []*endpoint.Endpoint
+	for _, e := range endpoints {
+		if !seen[e.String()] {
+			seen[e.String()] = true
+			result = append(result, e)
+		}
+	}
+	return result
+}
+
 // normalizeDNSName converts a DNS name to a canonical form, so that we can use string equality
 // it: removes space, converts to lower case, ensures there is a trailing dot
 func normalizeDNSName(dnsName string) string {
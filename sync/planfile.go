@@ -0,0 +1,177 @@
+package sync
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// planFileVersion is the version of the plan file format written by this release
+const planFileVersion = 1
+
+// PlanFile is a reviewable set of changes for all zones and targets, created by Plan and applied by Apply
+type PlanFile struct {
+	Version   int        `json:"version"`
+	CreatedAt time.Time  `json:"created_at"`
+	Zones     []ZonePlan `json:"zones"`
+}
+
+// ZonePlan holds the planned changes of a zone
+type ZonePlan struct {
+	Zone    string       `json:"zone"`
+	Targets []TargetPlan `json:"targets"`
+}
+
+// TargetPlan holds the planned changes of a target, along with the fingerprint of the records on the
+// target they were calculated from
+type TargetPlan struct {
+	Index       int           `json:"index"`
+	Provider    string        `json:"provider"`
+	Fingerprint string        `json:"fingerprint"`
+	Changes     *plan.Changes `json:"changes"`
+}
+
+// ReadPlanFile reads a plan file written by WritePlanFile
+func ReadPlanFile(path string) (*PlanFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read plan file")
+	}
+
+	var planFile PlanFile
+	if err := json.Unmarshal(data, &planFile); err != nil {
+		return nil, errors.Wrapf(err, "failed to parse plan file %s", path)
+	}
+	if planFile.Version != planFileVersion {
+		return nil, errors.Errorf("unsupported plan file version %d, expected %d", planFile.Version, planFileVersion)
+	}
+	return &planFile, nil
+}
+
+// WritePlanFile writes a plan file as indented JSON
+func WritePlanFile(path string, planFile *PlanFile) error {
+	data, err := json.MarshalIndent(planFile, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode plan file")
+	}
+	return errors.Wrap(os.WriteFile(path, append(data, '\n'), 0600), "failed to write plan file")
+}
+
+// Plan calculates the changes needed to sync all zones to their targets without applying them.
+// Targets whose changes violate a safety threshold fail the plan.
+func (s *Synchronizer) Plan(ctx context.Context) (*PlanFile, error) {
+	planFile := &PlanFile{Version: planFileVersion, CreatedAt: time.Now().UTC()}
+
+	for _, zoneConfig := range s.config.Zones {
+		desired, err := s.sourceRecords(ctx, zoneConfig)
+		if err != nil {
+			return nil, err
+		}
+
+		zonePlan := ZonePlan{Zone: zoneConfig.Name}
+		for i, targetConfig := range zoneConfig.Targets {
+			target, err := s.connectTarget(ctx, zoneConfig, i)
+			if err != nil {
+				return nil, err
+			}
+			p, err := s.planTarget(zoneConfig, targetConfig, target, desired)
+			if err != nil {
+				return nil, err
+			}
+			if err := checkSafety(safetyFor(zoneConfig, targetConfig), len(p.Desired), len(target.current), p.Changes); err != nil {
+				return nil, errors.Wrapf(err, "refusing plan for target %s of zone %s", target.name, zoneConfig.Name)
+			}
+
+			zonePlan.Targets = append(zonePlan.Targets, TargetPlan{
+				Index:       i,
+				Provider:    target.name,
+				Fingerprint: fingerprint(target.current),
+				Changes:     p.Changes,
+			})
+		}
+		planFile.Zones = append(planFile.Zones, zonePlan)
+	}
+
+	return planFile, nil
+}
+
+// Apply applies exactly the changes of a plan file. Nothing is applied unless all targets still hold
+// the records the plan was calculated from.
+func (s *Synchronizer) Apply(ctx context.Context, planFile *PlanFile) error {
+	type pendingChanges struct {
+		zone    string
+		index   int
+		target  *connectedTarget
+		changes *plan.Changes
+	}
+
+	var pending []pendingChanges
+	for _, zonePlan := range planFile.Zones {
+		zoneConfig := s.findZoneConfig(zonePlan.Zone)
+		if zoneConfig == nil {
+			return errors.Errorf("zone %s of the plan is not configured", zonePlan.Zone)
+		}
+
+		for _, targetPlan := range zonePlan.Targets {
+			if targetPlan.Index < 0 || targetPlan.Index >= len(zoneConfig.Targets) ||
+				zoneConfig.Targets[targetPlan.Index].ProviderConfig.String() != targetPlan.Provider {
+				return errors.Errorf("target %d (%s) of zone %s does not match the configuration", targetPlan.Index, targetPlan.Provider, zonePlan.Zone)
+			}
+			if targetPlan.Changes == nil || !targetPlan.Changes.HasChanges() {
+				continue
+			}
+
+			target, err := s.connectTarget(ctx, zoneConfig, targetPlan.Index)
+			if err != nil {
+				return err
+			}
+			if fingerprint(target.current) != targetPlan.Fingerprint {
+				return errors.Errorf("target %s of zone %s changed since the plan was created", target.name, zonePlan.Zone)
+			}
+			pending = append(pending, pendingChanges{zone: zoneConfig.Name, index: targetPlan.Index, target: target, changes: targetPlan.Changes})
+		}
+	}
+
+	for _, p := range pending {
+		logger := log.WithFields(log.Fields{"zone": p.zone, "target": p.target.name})
+		if s.config.Sync.DryRun {
+			logger.Info("Dry run enabled, skipping apply changes")
+			continue
+		}
+		if err := s.snapshotTarget(p.zone, p.index, p.target); err != nil {
+			return err
+		}
+		if err := p.target.provider.ApplyChanges(ctx, p.changes); err != nil {
+			providerErrors.WithLabelValues(p.zone, p.target.name, "apply").Inc()
+			return errors.Wrapf(err, "failed to apply changes to target %s for zone %s", p.target.name, p.zone)
+		}
+		s.journalChanges(p.zone, p.target.name, p.changes)
+		logger.WithFields(log.Fields{"creates": len(p.changes.Create), "updates": len(p.changes.UpdateNew), "deletes": len(p.changes.Delete)}).
+			Info("Applied changes")
+	}
+	return nil
+}
+
+// fingerprint returns a hash of records that does not depend on their order
+func fingerprint(records []*endpoint.Endpoint) string {
+	lines := make([]string, 0, len(records))
+	for _, record := range records {
+		lines = append(lines, record.String())
+	}
+	sort.Strings(lines)
+
+	hash := sha256.New()
+	for _, line := range lines {
+		hash.Write([]byte(line + "\n"))
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
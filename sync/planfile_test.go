@@ -0,0 +1,66 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanAndApply(t *testing.T) {
+	source, _ := os.CreateTemp("", "zones.bind")
+	target, _ := os.CreateTemp("", "target.bind")
+	_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+	_ = os.WriteFile(target.Name(), []byte("orphan.example.com. 300 IN A 10.0.0.1\n"), 0600)
+
+	cfg := config.Config{
+		Zones: []*config.ZoneConfig{
+			{
+				Name: "example.com",
+				Source: config.SourceConfig{
+					ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+				},
+				Targets: []config.TargetConfig{
+					{ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}}},
+				},
+			},
+		},
+	}
+
+	planFile, err := NewSynchronizer(cfg).Plan(context.Background())
+	assert.NoError(t, err)
+	assert.Len(t, planFile.Zones, 1)
+	assert.Len(t, planFile.Zones[0].Targets, 1)
+	changes := planFile.Zones[0].Targets[0].Changes
+	assert.Equal(t, 11, len(changes.Create))
+	assert.Equal(t, 1, len(changes.Delete))
+
+	// Planning does not change the target
+	records, _ := os.ReadFile(target.Name())
+	assert.Equal(t, "orphan.example.com. 300 IN A 10.0.0.1\n", string(records))
+
+	path := filepath.Join(t.TempDir(), "plan.json")
+	assert.NoError(t, WritePlanFile(path, planFile))
+	planFile, err = ReadPlanFile(path)
+	assert.NoError(t, err)
+
+	// The source changing after the plan was created does not change what is applied
+	_ = os.WriteFile(source.Name(), []byte("$ORIGIN example.com.\nwww IN A 192.0.2.80\n"), 0600)
+	assert.NoError(t, NewSynchronizer(cfg).Apply(context.Background(), planFile))
+
+	records, _ = os.ReadFile(target.Name())
+	assert.Contains(t, string(records), "ldap-backup.example.com.")
+	assert.NotContains(t, string(records), "orphan.example.com.")
+
+	// The target has changed since the plan was created
+	err = NewSynchronizer(cfg).Apply(context.Background(), planFile)
+	assert.ErrorContains(t, err, "changed since the plan was created")
+
+	// Plans can only be applied to the targets they were created for
+	planFile.Zones[0].Targets[0].Provider = "File{/tmp/other.bind}"
+	err = NewSynchronizer(cfg).Apply(context.Background(), planFile)
+	assert.ErrorContains(t, err, "does not match the configuration")
+}
@@ -0,0 +1,60 @@
+package sync
+
+import (
+	"github.com/flanksource/dns-sync/config"
+	"github.com/flanksource/dns-sync/config/providers"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+	"sigs.k8s.io/external-dns/provider"
+)
+
+// newLimiters returns a rate limiter for every provider account used by the zones, shared by the source
+// and targets using the account. Accounts use the first rate limit configured on one of their providers,
+// or the sync rate limit when none is. Unlimited accounts have no limiter.
+func newLimiters(cfg config.Config) map[string]*rate.Limiter {
+	providerConfigs := providerConfigs(cfg)
+
+	limits := make(map[string]config.RateLimitConfig)
+	for _, p := range providerConfigs {
+		if p.RateLimit == nil {
+			continue
+		}
+		account := p.Account()
+		if limit, ok := limits[account]; !ok {
+			limits[account] = *p.RateLimit
+		} else if limit != *p.RateLimit {
+			log.WithField("account", account).Warn("Ignoring rate limit, another provider using the account sets a different one")
+		}
+	}
+	for _, p := range providerConfigs {
+		if _, ok := limits[p.Account()]; !ok {
+			limits[p.Account()] = cfg.Sync.RateLimit
+		}
+	}
+
+	limiters := make(map[string]*rate.Limiter)
+	for account, limit := range limits {
+		if limiter := providers.NewRateLimiter(limit); limiter != nil {
+			limiters[account] = limiter
+		}
+	}
+	return limiters
+}
+
+// rateLimited wraps a provider with the rate limiter of its account
+func (s *Synchronizer) rateLimited(p provider.Provider, providerConfig config.ProviderConfig) provider.Provider {
+	account := providerConfig.Account()
+	return providers.NewRateLimitedProvider(p, account, s.limiters[account])
+}
+
+// providerConfigs returns the providers of the sources and targets of all zones
+func providerConfigs(cfg config.Config) []config.ProviderConfig {
+	var result []config.ProviderConfig
+	for _, zone := range cfg.Zones {
+		result = append(result, zone.Source.ProviderConfig)
+		for _, target := range zone.Targets {
+			result = append(result, target.ProviderConfig)
+		}
+	}
+	return result
+}
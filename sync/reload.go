@@ -0,0 +1,107 @@
+package sync
+
+import (
+	"slices"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/flanksource/dns-sync/journal"
+	"github.com/flanksource/dns-sync/snapshot"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
+)
+
+// Reload validates a new configuration and queues it to replace the current one once the running sync
+// has completed. A configuration queued earlier that has not been applied yet is discarded.
+func (s *Synchronizer) Reload(cfg config.Config) error {
+	if err := Validate(cfg); err != nil {
+		return errors.Wrap(err, "invalid configuration")
+	}
+
+	for {
+		select {
+		case s.reload <- cfg:
+			return nil
+		default:
+		}
+		// Drop the pending configuration in favour of the new one
+		select {
+		case <-s.reload:
+		default:
+		}
+	}
+}
+
+// Validate validates a configuration, along with the parts of it that are only parsed when zones are synced
+func Validate(cfg config.Config) error {
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	for _, zone := range cfg.Zones {
+		for i, target := range zone.Targets {
+			if target.Rewrite != nil {
+				if _, err := newRewriter(*target.Rewrite); err != nil {
+					return errors.Wrapf(err, "invalid rewrite for target %d of zone %s", i, zone.Name)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// applyConfig replaces the configuration of the synchronizer, returning the names of the zones that were
+// added and removed. It must only be called by the main loop, between syncs.
+func (s *Synchronizer) applyConfig(cfg config.Config) (added, removed []string) {
+	setZoneDefaults(cfg.Zones)
+
+	oldZones := make(map[string]bool)
+	for _, zone := range s.config.Zones {
+		oldZones[normalizeDNSName(zone.Name)] = true
+	}
+	newZones := make(map[string]bool)
+	for _, zone := range cfg.Zones {
+		name := normalizeDNSName(zone.Name)
+		newZones[name] = true
+		if !oldZones[name] {
+			added = append(added, zone.Name)
+		}
+	}
+	for _, zone := range s.config.Zones {
+		if !newZones[normalizeDNSName(zone.Name)] {
+			removed = append(removed, zone.Name)
+		}
+	}
+
+	oldNotify, newNotify := s.config.Sync.Notify, cfg.Sync.Notify
+	if cfg.Sync.EnableNotify != s.config.Sync.EnableNotify || cfg.Sync.NotifyPort != s.config.Sync.NotifyPort ||
+		!slices.Equal(newNotify.AllowedSources, oldNotify.AllowedSources) ||
+		newNotify.TSIGKeyName != oldNotify.TSIGKeyName || newNotify.TSIGSecret != oldNotify.TSIGSecret {
+		log.Warn("NOTIFY server settings changed, restart dns-sync to apply them")
+	}
+	if cfg.MetricsAddress != s.config.MetricsAddress {
+		log.Warn("Metrics address changed, restart dns-sync to apply it")
+	}
+
+	s.mu.Lock()
+	old := s.config
+	s.config = cfg
+	s.journal = journal.New(cfg.Journal)
+	s.snapshots = snapshot.New(cfg.Snapshots)
+	s.limiters = newLimiters(cfg)
+	s.notifyMu.Lock()
+	s.notifyLimiters = make(map[string]*rate.Limiter)
+	s.notifyMu.Unlock()
+	// Keep the circuit breakers, and the failures they have counted, unless their settings changed
+	if cfg.Sync.CircuitBreaker != old.Sync.CircuitBreaker {
+		circuitState.Reset()
+		s.breakers = newBreakerStore(cfg.Sync.CircuitBreaker)
+	} else {
+		s.breakers.prune(cfg.Zones)
+	}
+	s.mu.Unlock()
+
+	slices.Sort(added)
+	slices.Sort(removed)
+	log.WithFields(log.Fields{"config": cfg.Hash(), "zones": len(cfg.Zones), "added": added, "removed": removed}).Info("Reloaded configuration")
+	return added, removed
+}
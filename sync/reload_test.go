@@ -0,0 +1,150 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"sigs.k8s.io/external-dns/endpoint"
+	"sigs.k8s.io/external-dns/plan"
+	"sigs.k8s.io/external-dns/provider"
+	"sigs.k8s.io/external-dns/provider/inmemory"
+)
+
+func TestReload(t *testing.T) {
+	source, _ := os.CreateTemp("", "zones.bind")
+	_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+
+	zone := func(name string) *config.ZoneConfig {
+		target, _ := os.CreateTemp("", "target.bind")
+		return &config.ZoneConfig{
+			Name: name,
+			Source: config.SourceConfig{
+				ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+			},
+			Targets: []config.TargetConfig{
+				{ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}}},
+			},
+		}
+	}
+	zoneNames := func(s *Synchronizer) []string {
+		var names []string
+		for _, status := range s.GetStatus() {
+			names = append(names, status.Name)
+		}
+		return names
+	}
+
+	s := NewSynchronizer(config.Config{
+		Sync:  config.SyncConfig{Interval: time.Hour},
+		Zones: []*config.ZoneConfig{zone("example.com"), zone("example.net")},
+	})
+
+	// Invalid configurations are rejected and never queued
+	invalid := config.Config{Sync: config.SyncConfig{Interval: time.Hour}, Zones: []*config.ZoneConfig{zone("example.com"), zone("Example.com.")}}
+	assert.ErrorContains(t, s.Reload(invalid), "zone Example.com. is already configured by zones[0]")
+	invalid = config.Config{Sync: config.SyncConfig{Interval: time.Hour}, Zones: []*config.ZoneConfig{zone("example.com")}}
+	invalid.Zones[0].Targets[0].Policy = "mirror"
+	assert.ErrorContains(t, s.Reload(invalid), `unknown policy "mirror"`)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() { _ = s.Start(ctx) }()
+	require.Eventually(t, func() bool { return len(zoneNames(s)) == 2 }, 5*time.Second, 10*time.Millisecond)
+
+	// Only the most recent pending configuration is applied
+	require.NoError(t, s.Reload(config.Config{Sync: config.SyncConfig{Interval: time.Hour}, Zones: []*config.ZoneConfig{zone("example.com")}}))
+	require.NoError(t, s.Reload(config.Config{Sync: config.SyncConfig{Interval: time.Hour}, Zones: []*config.ZoneConfig{zone("example.com"), zone("example.org")}}))
+	require.Eventually(t, func() bool {
+		names := zoneNames(s)
+		return len(names) == 2 && names[1] == "example.org"
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.NotNil(t, s.findZoneConfig("example.org."))
+	assert.Nil(t, s.findZoneConfig("example.net"))
+}
+
+func TestApplyConfig(t *testing.T) {
+	s := NewSynchronizer(config.Config{Zones: []*config.ZoneConfig{{Name: "a.example.com"}, {Name: "b.example.com"}}})
+
+	added, removed := s.applyConfig(config.Config{Zones: []*config.ZoneConfig{{Name: "c.example.com"}, {Name: "B.example.com."}}})
+	assert.Equal(t, []string{"c.example.com"}, added)
+	assert.Equal(t, []string{"a.example.com"}, removed)
+	assert.NotEmpty(t, s.findZoneConfig("c.example.com").RecordFilter.IncludeTypes)
+}
+
+func TestApplyConfigBreakers(t *testing.T) {
+	file := func(path string) config.ProviderConfig {
+		return config.ProviderConfig{File: &config.FileProviderConfig{Path: path}}
+	}
+	zone := func(name string, targets ...string) *config.ZoneConfig {
+		zone := &config.ZoneConfig{Name: name, Source: config.SourceConfig{ProviderConfig: file("source.bind")}}
+		for _, target := range targets {
+			zone.Targets = append(zone.Targets, config.TargetConfig{ProviderConfig: file(target)})
+		}
+		return zone
+	}
+	breakers := config.SyncConfig{CircuitBreaker: config.CircuitBreakerConfig{Failures: 1, Timeout: time.Minute}}
+
+	s := NewSynchronizer(config.Config{Sync: breakers, Zones: []*config.ZoneConfig{zone("example.com", "a.bind", "b.bind"), zone("example.net", "a.bind")}})
+	// Providers using the same account for a zone share a circuit breaker, whichever target index they are used for,
+	// while each zone of the account has its own
+	shared := s.breakers.get("example.com", file("a.bind").Account())
+	assert.Same(t, shared, s.breakers.get("example.com", file("a.bind").Account()))
+	assert.NotSame(t, shared, s.breakers.get("example.net", file("a.bind").Account()))
+	s.breakers.get("example.com", file("b.bind").Account())
+
+	// Reordering targets keeps their circuit breakers, the breakers of removed targets and zones are dropped
+	s.applyConfig(config.Config{Sync: breakers, Zones: []*config.ZoneConfig{zone("example.com", "c.bind", "a.bind")}})
+	assert.Same(t, shared, s.breakers.get("example.com", file("a.bind").Account()))
+	assert.Empty(t, s.breakers.state("example.com", file("b.bind").Account()))
+	assert.Empty(t, s.breakers.state("example.net", file("a.bind").Account()))
+	assert.Len(t, s.breakers.breakers, 1)
+}
+
+// failingProvider fails every call
+type failingProvider struct {
+	provider.BaseProvider
+	calls int
+}
+
+func (p *failingProvider) Records(context.Context) ([]*endpoint.Endpoint, error) {
+	p.calls++
+	return nil, errors.New("zone unavailable")
+}
+
+func (p *failingProvider) ApplyChanges(context.Context, *plan.Changes) error {
+	p.calls++
+	return errors.New("zone unavailable")
+}
+
+func TestBreakersPerZone(t *testing.T) {
+	// Providers configured from the environment use one account for all of their zones
+	inMemory := config.ProviderConfig{InMemory: &config.InMemoryProviderConfig{}}
+	breakers := config.SyncConfig{CircuitBreaker: config.CircuitBreakerConfig{Failures: 2, Timeout: time.Minute}}
+	s := NewSynchronizer(config.Config{Sync: breakers})
+	ctx := context.Background()
+
+	failing := &failingProvider{}
+	broken := s.resilient(failing, inMemory, "broken.example.com")
+	for range 3 {
+		_, err := broken.Records(ctx)
+		require.Error(t, err)
+	}
+	assert.Equal(t, 2, failing.calls, "the circuit of the failing zone should open")
+	assert.Equal(t, "open", s.breakers.state("broken.example.com", inMemory.Account()))
+
+	// A second zone of the same provider keeps syncing
+	p := inmemory.NewInMemoryProvider()
+	require.NoError(t, p.CreateZone("example.com"))
+	healthy := s.resilient(p, inMemory, "example.com")
+	require.NoError(t, healthy.ApplyChanges(ctx, &plan.Changes{Create: []*endpoint.Endpoint{endpoint.NewEndpoint("www.example.com", "A", "192.0.2.1")}}))
+	records, err := healthy.Records(ctx)
+	require.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, "closed", s.breakers.state("example.com", inMemory.Account()))
+}
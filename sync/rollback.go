@@ -0,0 +1,119 @@
+package sync
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/flanksource/dns-sync/snapshot"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// TargetIndex resolves a target of a zone given either its index or its provider name
+func (s *Synchronizer) TargetIndex(zone, target string) (int, error) {
+	zoneConfig := s.findZoneConfig(zone)
+	if zoneConfig == nil {
+		return 0, errors.Errorf("zone %s is not configured", zone)
+	}
+
+	if index, err := strconv.Atoi(target); err == nil {
+		if index < 0 || index >= len(zoneConfig.Targets) {
+			return 0, errors.Errorf("zone %s has no target %d", zone, index)
+		}
+		return index, nil
+	}
+	for i, targetConfig := range zoneConfig.Targets {
+		if targetConfig.ProviderConfig.String() == target {
+			return i, nil
+		}
+	}
+	return 0, errors.Errorf("zone %s has no target %s", zone, target)
+}
+
+// Snapshots returns the snapshots of the target at index of a zone, oldest first
+func (s *Synchronizer) Snapshots(zone string, index int) ([]*snapshot.Snapshot, error) {
+	zoneConfig, _, err := s.rollbackTarget(zone, index)
+	if err != nil {
+		return nil, err
+	}
+	return s.snapshots.List(zoneConfig.Name, index)
+}
+
+// Rollback restores the records of the target at index of a zone to those of a snapshot, by applying
+// the changes calculated from the snapshot and the current records. The current records are snapshotted
+// first, so that a rollback can be rolled back as well.
+func (s *Synchronizer) Rollback(ctx context.Context, zone string, index int, id string) (*plan.Changes, error) {
+	zoneConfig, targetConfig, err := s.rollbackTarget(zone, index)
+	if err != nil {
+		return nil, err
+	}
+
+	snap, err := s.snapshots.Load(zoneConfig.Name, index, id)
+	if err != nil {
+		return nil, err
+	}
+	if snap.Target != targetConfig.ProviderConfig.String() {
+		return nil, errors.Errorf("snapshot %s was taken of target %s, not %s", id, snap.Target, targetConfig.ProviderConfig.String())
+	}
+
+	target, err := s.connectTarget(ctx, zoneConfig, index)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only the records managed on the target are restored, using the filters of the current configuration
+	desired, err := s.filterTargetRecords(snap.Records, zoneConfig, targetConfig)
+	if err != nil {
+		return nil, err
+	}
+	if targetConfig.RecordFilter != nil {
+		if desired, err = s.filterRecords(desired, *targetConfig.RecordFilter); err != nil {
+			return nil, err
+		}
+	}
+	p := Calculate(&plan.Plan{
+		Desired:        desired,
+		Current:        target.current,
+		DomainFilter:   target.domainFilter,
+		ManagedRecords: managedRecordTypes(zoneConfig, targetConfig),
+		Policies:       []plan.Policy{&plan.SyncPolicy{}},
+		OwnerID:        target.ownerID,
+	})
+
+	if !p.Changes.HasChanges() {
+		return p.Changes, nil
+	}
+	if s.config.Sync.DryRun {
+		log.WithFields(log.Fields{"zone": zoneConfig.Name, "target": target.name}).Info("Dry run enabled, skipping rollback")
+		return p.Changes, nil
+	}
+
+	if err := s.snapshotTarget(zoneConfig.Name, index, target); err != nil {
+		return nil, err
+	}
+	if err := target.provider.ApplyChanges(ctx, p.Changes); err != nil {
+		providerErrors.WithLabelValues(zoneConfig.Name, target.name, "apply").Inc()
+		return nil, errors.Wrapf(err, "failed to roll back target %s of zone %s", target.name, zoneConfig.Name)
+	}
+	s.journalChanges(zoneConfig.Name, target.name, p.Changes)
+	log.WithFields(log.Fields{"zone": zoneConfig.Name, "target": target.name, "snapshot": id}).Info("Rolled back target")
+
+	return p.Changes, nil
+}
+
+// rollbackTarget returns the configuration of the target at index of a zone, if snapshots are enabled
+func (s *Synchronizer) rollbackTarget(zone string, index int) (*config.ZoneConfig, config.TargetConfig, error) {
+	if s.snapshots == nil {
+		return nil, config.TargetConfig{}, errors.New("snapshots are not enabled")
+	}
+	zoneConfig := s.findZoneConfig(zone)
+	if zoneConfig == nil {
+		return nil, config.TargetConfig{}, errors.Errorf("zone %s is not configured", zone)
+	}
+	if index < 0 || index >= len(zoneConfig.Targets) {
+		return nil, config.TargetConfig{}, errors.Errorf("zone %s has no target %d", zone, index)
+	}
+	return zoneConfig, zoneConfig.Targets[index], nil
+}
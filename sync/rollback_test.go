@@ -0,0 +1,64 @@
+package sync
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollback(t *testing.T) {
+	source, _ := os.CreateTemp("", "zones.bind")
+	target, _ := os.CreateTemp("", "target.bind")
+	_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+	_ = os.WriteFile(target.Name(), []byte("manual.example.com. 300 IN A 10.0.0.1\n"), 0600)
+
+	cfg := config.Config{
+		Snapshots: config.SnapshotConfig{Dir: t.TempDir()},
+		Zones: []*config.ZoneConfig{
+			{
+				Name: "example.com",
+				Source: config.SourceConfig{
+					ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+				},
+				Targets: []config.TargetConfig{
+					{ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}}},
+				},
+			},
+		},
+	}
+	test(t, cfg, 11, 0, 1)
+
+	s := NewSynchronizer(cfg)
+	index, err := s.TargetIndex("example.com", cfg.Zones[0].Targets[0].ProviderConfig.String())
+	require.NoError(t, err)
+	assert.Equal(t, 0, index)
+	_, err = s.TargetIndex("example.com", "1")
+	assert.Error(t, err)
+
+	snapshots, err := s.Snapshots("example.com", 0)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Len(t, snapshots[0].Records, 1)
+
+	changes, err := s.Rollback(context.Background(), "example.com", 0, snapshots[0].ID)
+	require.NoError(t, err)
+	assert.Len(t, changes.Create, 1)
+	assert.Len(t, changes.Delete, 11)
+
+	records, _ := os.ReadFile(target.Name())
+	assert.Contains(t, string(records), "manual.example.com.")
+	assert.NotContains(t, string(records), "www.example.com.")
+
+	// The rollback can be undone using the snapshot taken before it
+	snapshots, err = s.Snapshots("example.com", 0)
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Len(t, snapshots[1].Records, 11)
+
+	_, err = NewSynchronizer(config.Config{Zones: cfg.Zones}).Rollback(context.Background(), "example.com", 0, snapshots[0].ID)
+	assert.ErrorContains(t, err, "snapshots are not enabled")
+}
@@ -0,0 +1,47 @@
+package sync
+
+import (
+	"github.com/flanksource/dns-sync/config"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/external-dns/plan"
+)
+
+// safetyFor returns the safety thresholds of a target, the thresholds set on the target override
+// those of the zone
+func safetyFor(zoneConfig *config.ZoneConfig, targetConfig config.TargetConfig) config.SafetyConfig {
+	var safety config.SafetyConfig
+	for _, override := range []*config.SafetyConfig{zoneConfig.Safety, targetConfig.Safety} {
+		if override == nil {
+			continue
+		}
+		if override.MaxDeletes > 0 {
+			safety.MaxDeletes = override.MaxDeletes
+		}
+		if override.MaxDeletePercent > 0 {
+			safety.MaxDeletePercent = override.MaxDeletePercent
+		}
+		if override.MinRecords > 0 {
+			safety.MinRecords = override.MinRecords
+		}
+	}
+	return safety
+}
+
+// checkSafety returns an error describing the first threshold violated by applying changes to a target
+// holding current records, in order to end up with desired records
+func checkSafety(safety config.SafetyConfig, desired, current int, changes *plan.Changes) error {
+	deletes := len(changes.Delete)
+
+	if safety.MinRecords > 0 && desired < safety.MinRecords {
+		return errors.Errorf("%d desired records is below the minimum of %d", desired, safety.MinRecords)
+	}
+	if safety.MaxDeletes > 0 && deletes > safety.MaxDeletes {
+		return errors.Errorf("%d deletes exceeds the maximum of %d", deletes, safety.MaxDeletes)
+	}
+	if safety.MaxDeletePercent > 0 && current > 0 {
+		if percent := float64(deletes) * 100 / float64(current); percent > safety.MaxDeletePercent {
+			return errors.Errorf("deleting %.1f%% of %d records exceeds the maximum of %.1f%%", percent, current, safety.MaxDeletePercent)
+		}
+	}
+	return nil
+}
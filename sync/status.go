@@ -0,0 +1,127 @@
+package sync
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// ZoneStatus represents the sync status of a zone
+type ZoneStatus struct {
+	Name         string         `json:"name"`
+	LastSync     time.Time      `json:"last_sync"`
+	LastSuccess  time.Time      `json:"last_success"`
+	LastError    string         `json:"last_error,omitempty"`
+	RecordCount  int            `json:"record_count"`
+	TargetStatus []TargetStatus `json:"target_status"`
+}
+
+// TargetStatus represents the sync status for a specific target
+type TargetStatus struct {
+	Provider    string    `json:"provider"`
+	ZoneID      string    `json:"zone_id"`
+	LastSync    time.Time `json:"last_sync"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+	RecordCount int       `json:"record_count"`
+	Creates     int       `json:"creates"`
+	Updates     int       `json:"updates"`
+	Deletes     int       `json:"deletes"`
+	Refused     bool      `json:"refused,omitempty"` // plan violated a safety threshold and was not applied
+	Circuit     string    `json:"circuit,omitempty"` // state of the circuit breaker of the target, when enabled
+}
+
+// statusStore keeps the outcome of the most recent sync of every zone and target,
+// it is safe for concurrent use
+type statusStore struct {
+	mu    sync.RWMutex
+	zones map[string]*ZoneStatus
+}
+
+func newStatusStore() *statusStore {
+	return &statusStore{
+		zones: make(map[string]*ZoneStatus),
+	}
+}
+
+// zone returns the status entry for a zone, creating it if needed. Callers must hold the lock.
+func (s *statusStore) zone(name string) *ZoneStatus {
+	status, ok := s.zones[name]
+	if !ok {
+		status = &ZoneStatus{Name: name}
+		s.zones[name] = status
+	}
+	return status
+}
+
+// zoneSynced records the result of syncing a zone from its source
+func (s *statusStore) zoneSynced(name string, recordCount int, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.zone(name)
+	status.LastSync = time.Now()
+	status.RecordCount = recordCount
+	if err != nil {
+		status.LastError = err.Error()
+	} else {
+		status.LastError = ""
+		status.LastSuccess = status.LastSync
+	}
+}
+
+// targetSynced records the result of syncing a zone to the target at index
+func (s *statusStore) targetSynced(name string, index int, result TargetStatus, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	status := s.zone(name)
+	for len(status.TargetStatus) <= index {
+		status.TargetStatus = append(status.TargetStatus, TargetStatus{})
+	}
+
+	previous := status.TargetStatus[index]
+	if err != nil {
+		result.LastError = err.Error()
+		if previous.Provider == result.Provider {
+			result.LastSuccess = previous.LastSuccess
+		}
+	} else {
+		result.LastError = ""
+		result.LastSuccess = result.LastSync
+	}
+	status.TargetStatus[index] = result
+}
+
+// prune removes zones that are no longer configured
+func (s *statusStore) prune(names []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keep := make(map[string]bool, len(names))
+	for _, name := range names {
+		keep[name] = true
+	}
+	for name := range s.zones {
+		if !keep[name] {
+			delete(s.zones, name)
+		}
+	}
+}
+
+// list returns a copy of all zone statuses sorted by zone name
+func (s *statusStore) list() []ZoneStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	statuses := make([]ZoneStatus, 0, len(s.zones))
+	for _, status := range s.zones {
+		copied := *status
+		copied.TargetStatus = append([]TargetStatus(nil), status.TargetStatus...)
+		statuses = append(statuses, copied)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
@@ -2,62 +2,127 @@ package sync
 
 import (
 	"context"
-	"log"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/flanksource/dns-sync/config"
 	"github.com/flanksource/dns-sync/config/providers"
+	"github.com/flanksource/dns-sync/journal"
+	"github.com/flanksource/dns-sync/snapshot"
 	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/time/rate"
 	"sigs.k8s.io/external-dns/endpoint"
 	"sigs.k8s.io/external-dns/plan"
 	"sigs.k8s.io/external-dns/provider"
 )
 
+// notifyQueueSize is the number of NOTIFY triggered syncs that can be pending at once
+const notifyQueueSize = 16
+
 // Synchronizer manages DNS zone synchronization
 type Synchronizer struct {
+	// mu guards config, which is only replaced by the main loop. The main loop reads it without locking,
+	// other goroutines must hold a read lock.
+	mu     sync.RWMutex
 	config config.Config
+
+	// reload receives validated configurations, applied by the main loop between syncs
+	reload chan config.Config
+
+	// notify receives zone names from the NOTIFY server, to be synced by the main loop
+	notify chan string
+
+	// notifyLimiters limits the syncs triggered by NOTIFY, by zone. It is guarded by notifyMu as NOTIFY
+	// messages are handled concurrently.
+	notifyMu       sync.Mutex
+	notifyLimiters map[string]*rate.Limiter
+
+	// status records the outcome of the most recent zone and target syncs
+	status *statusStore
+
+	// ready is set once all zones have been synced successfully
+	ready atomic.Bool
+
+	// journal records applied changes, nil when disabled
+	journal *journal.Journal
+
+	// snapshots stores the records of targets before changes are applied, nil when disabled
+	snapshots *snapshot.Store
+
+	// breakers stops calling providers that keep failing
+	breakers *breakerStore
+
+	// limiters limits the calls made to each provider account, by account
+	limiters map[string]*rate.Limiter
+}
+
+func NewSynchronizer(cfg config.Config) *Synchronizer {
+	setZoneDefaults(cfg.Zones)
+	return &Synchronizer{
+		config: cfg,
+		reload: make(chan config.Config, 1),
+		notify: make(chan string, notifyQueueSize),
+
+		notifyLimiters: make(map[string]*rate.Limiter),
+		status:         newStatusStore(),
+		journal:        journal.New(cfg.Journal),
+		snapshots:      snapshot.New(cfg.Snapshots),
+		breakers:       newBreakerStore(cfg.Sync.CircuitBreaker),
+		limiters:       newLimiters(cfg),
+	}
 }
 
-func NewSynchronizer(config config.Config) *Synchronizer {
-	for _, zone := range config.Zones {
+// setZoneDefaults applies default values to the zone configurations, and normalizes the record types of
+// their filters to the upper case used by providers
+func setZoneDefaults(zones []*config.ZoneConfig) {
+	for _, zone := range zones {
 		if len(zone.RecordFilter.IncludeTypes) == 0 {
 			zone.RecordFilter.IncludeTypes = []string{"A", "AAAA", "CNAME", "MX", "NS", "PTR", "SRV", "TXT"}
 		}
+		upperTypes(&zone.RecordFilter)
+		for _, target := range zone.Targets {
+			if target.RecordFilter != nil {
+				upperTypes(target.RecordFilter)
+			}
+		}
 	}
-	return &Synchronizer{
-		config: config,
+}
+
+func upperTypes(filter *config.RecordFilterConfig) {
+	for i, recordType := range filter.IncludeTypes {
+		filter.IncludeTypes[i] = strings.ToUpper(recordType)
+	}
+	for i, recordType := range filter.ExcludeTypes {
+		filter.ExcludeTypes[i] = strings.ToUpper(recordType)
 	}
 }
 
 // Start starts the synchronizer
 func (s *Synchronizer) Start(ctx context.Context) error {
-	// // Start notify server if enabled
-	// if s.config.Source.NotifyServer {
-	// 	s.notifyServer = zone.NewNotifyServer(s.zoneClient, s.RFC.NotifyPort)
-
-	// 	// Register notify handlers for each zone
-	// 	for _, zoneConfig := range s.zones {
-	// 		zoneName := zoneConfig.Name
-	// 		s.notifyServer.RegisterHandler(zoneName, func(ctx context.Context, zone string) error {
-	// 			return s.syncZone(ctx, s.findZoneConfig(zone))
-	// 		})
-	// 	}
-
-	// 	// Start notify server in a goroutine
-	// 	go func() {
-	// 		if err := s.notifyServer.Start(ctx); err != nil {
-	// 			log.Printf("Notify server error: %v", err)
-	// 		}
-	// 	}()
-	// }
+	// Start notify server if enabled
+	if s.config.Sync.EnableNotify {
+		server, err := NewNotifyServer(fmt.Sprintf(":%d", s.config.Sync.NotifyPort), s.config.Sync.Notify, s.notifyZone)
+		if err != nil {
+			return errors.Wrap(err, "invalid NOTIFY configuration")
+		}
+		go func() {
+			if err := server.Start(ctx); err != nil {
+				log.WithError(err).Error("NOTIFY server failed")
+			}
+		}()
+	}
 
 	// Start periodic sync
 	ticker := time.NewTicker(s.config.Sync.Interval)
 	defer ticker.Stop()
 
 	// Perform initial sync
-	if err, _ := s.syncAllZones(ctx); err != nil {
-		log.Printf("Initial sync failed: %v", err)
+	if _, err := s.syncAllZones(ctx); err != nil {
+		log.WithError(err).Error("Initial sync failed")
 	}
 
 	// Main sync loop
@@ -66,8 +131,25 @@ func (s *Synchronizer) Start(ctx context.Context) error {
 		case <-ctx.Done():
 			return ctx.Err()
 		case <-ticker.C:
-			if err, _ := s.syncAllZones(ctx); err != nil {
-				log.Printf("Periodic sync failed: %v", err)
+			if _, err := s.syncAllZones(ctx); err != nil {
+				log.WithError(err).Error("Periodic sync failed")
+			}
+		case cfg := <-s.reload:
+			interval := s.config.Sync.Interval
+			s.applyConfig(cfg)
+			if s.config.Sync.Interval != interval {
+				ticker.Reset(s.config.Sync.Interval)
+			}
+			if _, err := s.syncAllZones(ctx); err != nil {
+				log.WithError(err).Error("Sync after configuration reload failed")
+			}
+		case zone := <-s.notify:
+			zoneConfig := s.findZoneConfig(zone)
+			if zoneConfig == nil {
+				continue
+			}
+			if _, err := s.syncZone(ctx, zoneConfig); err != nil {
+				log.WithField("zone", zoneConfig.Name).WithError(err).Error("Failed to sync zone after NOTIFY")
 			}
 		}
 	}
@@ -78,216 +160,422 @@ func (s *Synchronizer) Once(ctx context.Context) (map[string]map[config.TargetCo
 	return s.syncAllZones(ctx)
 }
 
-// SetZones sets the zones to synchronize
+// SetZones sets the zones to synchronize, it must not be called while the synchronizer is running.
+// Use Reload to change the zones of a running synchronizer.
 func (s *Synchronizer) SetZones(zones []*config.ZoneConfig) {
+	setZoneDefaults(zones)
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.config.Zones = zones
 }
 
+// findZoneConfig returns the configuration of the zone with the given name, or nil if it is not managed
+func (s *Synchronizer) findZoneConfig(name string) *config.ZoneConfig {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	name = normalizeDNSName(name)
+	for _, zoneConfig := range s.config.Zones {
+		if normalizeDNSName(zoneConfig.Name) == name {
+			return zoneConfig
+		}
+	}
+	return nil
+}
+
+// notifyZone queues a sync of the notified zone, returning false if the zone is not managed. NOTIFY messages
+// received less than notify.min_interval after the one that last queued a sync of the zone are ignored.
+func (s *Synchronizer) notifyZone(name string) bool {
+	zoneConfig := s.findZoneConfig(name)
+	if zoneConfig == nil {
+		return false
+	}
+	if !s.notifyAllowed(zoneConfig.Name) {
+		log.WithField("zone", zoneConfig.Name).Info("Ignoring NOTIFY, the zone was notified less than min_interval ago")
+		return true
+	}
+
+	select {
+	case s.notify <- zoneConfig.Name:
+	default:
+		log.WithField("zone", zoneConfig.Name).Warn("NOTIFY queue full, dropping NOTIFY")
+	}
+	return true
+}
+
+// notifyAllowed returns true if a NOTIFY of the zone may trigger a sync, consuming the allowance of the zone
+func (s *Synchronizer) notifyAllowed(zone string) bool {
+	s.mu.RLock()
+	interval := s.config.Sync.Notify.MinInterval
+	s.mu.RUnlock()
+	if interval <= 0 {
+		return true
+	}
+
+	s.notifyMu.Lock()
+	defer s.notifyMu.Unlock()
+	zone = normalizeDNSName(zone)
+	limiter, ok := s.notifyLimiters[zone]
+	if !ok {
+		limiter = rate.NewLimiter(rate.Every(interval), 1)
+		s.notifyLimiters[zone] = limiter
+	}
+	return limiter.Allow()
+}
+
 // syncAllZones synchronizes all configured zones
 func (s *Synchronizer) syncAllZones(ctx context.Context) (map[string]map[config.TargetConfig]*plan.Changes, error) {
 	changes := make(map[string]map[config.TargetConfig]*plan.Changes)
+	names := make([]string, 0, len(s.config.Zones))
+	failed := 0
 	for _, zoneConfig := range s.config.Zones {
-		if chg, err := s.syncZone(ctx, zoneConfig); err != nil {
-			log.Printf("Failed to sync zone %s: %v", zoneConfig.Name, err)
+		names = append(names, zoneConfig.Name)
+		chg, err := s.syncZone(ctx, zoneConfig)
+		if err != nil {
+			log.WithField("zone", zoneConfig.Name).WithError(err).Error("Failed to sync zone")
+			failed++
 			// Continue with other zones
-		} else {
+		}
+		if chg != nil {
 			changes[zoneConfig.Name] = chg
 		}
 	}
+	s.status.prune(names)
+
+	if failed > 0 {
+		return changes, errors.Errorf("failed to sync %d of %d zones", failed, len(s.config.Zones))
+	}
+	s.ready.Store(true)
 	return changes, nil
 }
 
-// syncZone synchronizes a single zone
+// syncZone synchronizes a single zone to all of its targets, a failing target does not prevent
+// the remaining targets from being synced
 func (s *Synchronizer) syncZone(ctx context.Context, zoneConfig *config.ZoneConfig) (map[config.TargetConfig]*plan.Changes, error) {
-	log.Printf("Starting sync for zone: %s", zoneConfig.Name)
+	logger := log.WithField("zone", zoneConfig.Name)
+	logger.Info("Starting sync")
 
 	changes := make(map[config.TargetConfig]*plan.Changes)
 
-	source, _ := providers.GetProvider(ctx, zoneConfig.Source.ProviderConfig, zoneConfig.Source.DomainFilter, zoneConfig.Source.RecordFilter, s.config.Sync.DryRun)
-
-	desired, err := s.listRecords(ctx, source, *zoneConfig)
+	desired, err := s.sourceRecords(ctx, zoneConfig)
 	if err != nil {
+		s.status.zoneSynced(zoneConfig.Name, 0, err)
 		return nil, err
 	}
-	for _, targetConfig := range zoneConfig.Targets {
-		target, err := providers.GetProvider(ctx, targetConfig.ProviderConfig, zoneConfig.Source.DomainFilter, zoneConfig.Source.RecordFilter, s.config.Sync.DryRun)
+
+	failed := 0
+	for i, targetConfig := range zoneConfig.Targets {
+		chg, err := s.syncTarget(ctx, zoneConfig, i, desired)
 		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get target provider for %s", targetConfig.ProviderConfig.String())
+			logger.WithField("target", targetConfig.ProviderConfig.String()).WithError(err).Error("Failed to sync target")
+			failed++
+			continue
 		}
-		current, err := s.listRecords(ctx, target, *zoneConfig)
-		if err != nil {
-			return nil, errors.Wrapf(err, "failed to get current records from target provider for %s", targetConfig.ProviderConfig.String())
+		if chg != nil {
+			changes[targetConfig] = chg
 		}
+	}
 
-		p := &plan.Plan{
-			Desired:        desired,
-			Current:        current,
-			ManagedRecords: zoneConfig.RecordFilter.IncludeTypes,
-			Policies: []plan.Policy{
-				&plan.SyncPolicy{},
-			},
-		}
+	if failed > 0 {
+		err = errors.Errorf("failed to sync %d of %d targets", failed, len(zoneConfig.Targets))
+	}
+	s.status.zoneSynced(zoneConfig.Name, len(desired), err)
+	logger.Info("Completed sync")
 
-		p = Calculate(p)
-		for _, i := range p.Changes.Create {
-			log.Printf("+%s\n", i.String())
-		}
-		for _, i := range p.Changes.UpdateNew {
-			log.Printf("~%s\n", i.String())
-		}
-		for _, i := range p.Changes.UpdateOld {
-			log.Printf("~%s\n", i.String())
+	return changes, err
+}
+
+// syncTarget synchronizes the desired records of a zone to the target at index, recording the outcome
+// in the sync status. It returns nil changes when running in dry run mode.
+func (s *Synchronizer) syncTarget(ctx context.Context, zoneConfig *config.ZoneConfig, index int, desired []*endpoint.Endpoint) (_ *plan.Changes, err error) {
+	targetConfig := zoneConfig.Targets[index]
+	targetName := targetConfig.ProviderConfig.String()
+	logger := log.WithFields(log.Fields{"zone": zoneConfig.Name, "target": targetName})
+	status := TargetStatus{
+		Provider: targetName,
+		ZoneID:   targetZoneName(zoneConfig, targetConfig),
+		LastSync: time.Now(),
+	}
+	defer func() {
+		syncDuration.WithLabelValues(zoneConfig.Name, targetName).Observe(time.Since(status.LastSync).Seconds())
+		if err == nil {
+			lastSuccess.WithLabelValues(zoneConfig.Name, targetName).SetToCurrentTime()
 		}
-		for _, i := range p.Changes.Delete {
-			log.Printf("-%s\n", i.String())
+		status.Circuit = s.breakers.state(status.ZoneID, targetConfig.ProviderConfig.Account())
+		s.status.targetSynced(zoneConfig.Name, index, status, err)
+	}()
+
+	target, err := s.connectTarget(ctx, zoneConfig, index)
+	if err != nil {
+		return nil, err
+	}
+	status.RecordCount = len(target.current)
+
+	p, err := s.planTarget(zoneConfig, targetConfig, target, desired)
+	if err != nil {
+		return nil, err
+	}
+	logChanges(logger, p.Changes)
+	status.Creates = len(p.Changes.Create)
+	status.Updates = len(p.Changes.UpdateNew)
+	status.Deletes = len(p.Changes.Delete)
+	planChanges.WithLabelValues(zoneConfig.Name, targetName, "create").Add(float64(status.Creates))
+	planChanges.WithLabelValues(zoneConfig.Name, targetName, "update").Add(float64(status.Updates))
+	planChanges.WithLabelValues(zoneConfig.Name, targetName, "delete").Add(float64(status.Deletes))
+	logger.WithFields(log.Fields{"creates": status.Creates, "updates": status.Updates, "deletes": status.Deletes}).Info("Planned changes")
+
+	if err := checkSafety(safetyFor(zoneConfig, targetConfig), len(p.Desired), len(target.current), p.Changes); err != nil {
+		status.Refused = true
+		plansRefused.WithLabelValues(zoneConfig.Name, targetName).Inc()
+		logger.WithError(err).Warn("Refusing plan")
+		return nil, errors.Wrapf(err, "refusing to apply plan for target %s of zone %s", targetName, zoneConfig.Name)
+	}
+
+	if s.config.Sync.DryRun {
+		logger.Info("Dry run enabled, skipping apply changes")
+		return nil, nil
+	}
+	if p.Changes.HasChanges() {
+		if err := s.snapshotTarget(zoneConfig.Name, index, target); err != nil {
+			return nil, err
 		}
-		log.Printf("Sync %s (%s): %d creates, %d updates, %d deletes", zoneConfig.Name, targetConfig.ProviderConfig.String(),
-			len(p.Changes.Create), len(p.Changes.UpdateNew)+len(p.Changes.UpdateOld), len(p.Changes.Delete))
+	}
+	if err := target.provider.ApplyChanges(ctx, p.Changes); err != nil {
+		providerErrors.WithLabelValues(zoneConfig.Name, targetName, "apply").Inc()
+		return nil, errors.Wrapf(err, "failed to apply changes to target %s for zone %s", targetName, zoneConfig.Name)
+	}
+	s.journalChanges(zoneConfig.Name, targetName, p.Changes)
 
-		if s.config.Sync.DryRun {
-			log.Printf("Dry run enabled, skipping apply changes for target %s", targetConfig.ProviderConfig.String())
-			continue
-		} else if err := target.ApplyChanges(ctx, p.Changes); err != nil {
-			return nil, errors.Wrapf(err, "failed to apply changes to target %s for zone %s", targetConfig.ProviderConfig.String(), zoneConfig.Name)
+	return p.Changes, nil
+}
+
+// logChanges logs every planned change, with the name and type of its record
+func logChanges(logger *log.Entry, changes *plan.Changes) {
+	for _, action := range []struct {
+		name    string
+		prefix  string
+		records []*endpoint.Endpoint
+	}{
+		{"create", "+", changes.Create},
+		{"update-old", "~", changes.UpdateOld},
+		{"update-new", "~", changes.UpdateNew},
+		{"delete", "-", changes.Delete},
+	} {
+		for _, record := range action.records {
+			logger.WithFields(log.Fields{"action": action.name, "record": record.DNSName, "type": record.RecordType}).
+				Info(action.prefix + record.String())
 		}
+	}
+}
 
-		changes[targetConfig] = p.Changes
+// journalChanges records applied changes in the journal. Failures are only logged, as the changes
+// have already been applied.
+func (s *Synchronizer) journalChanges(zone, target string, changes *plan.Changes) {
+	if s.journal == nil || !changes.HasChanges() {
+		return
+	}
+	entry := journal.Entry{
+		Time:       time.Now().UTC(),
+		Zone:       zone,
+		Target:     target,
+		ConfigHash: s.config.Hash(),
+		Changes:    changes,
 	}
-	log.Printf("Completed sync for zone: %s", zoneConfig.Name)
+	if err := s.journal.Append(entry); err != nil {
+		log.WithFields(log.Fields{"zone": zone, "target": target}).WithError(err).Error("Failed to journal changes")
+	}
+}
 
-	return changes, nil
+// snapshotTarget saves the records of the target at index of a zone, before changes are applied to it
+func (s *Synchronizer) snapshotTarget(zone string, index int, target *connectedTarget) error {
+	if s.snapshots == nil {
+		return nil
+	}
+	id, err := s.snapshots.Save(zone, index, target.name, target.records)
+	if err != nil {
+		return errors.Wrapf(err, "failed to snapshot target %s of zone %s", target.name, zone)
+	}
+	log.WithFields(log.Fields{"zone": zone, "target": target.name, "snapshot": id}).Info("Saved snapshot")
+	return nil
 }
 
-func (s *Synchronizer) listRecords(ctx context.Context, p provider.Provider, zone config.ZoneConfig) ([]*endpoint.Endpoint, error) {
+// sourceRecords fetches the desired records of a zone from its source
+func (s *Synchronizer) sourceRecords(ctx context.Context, zoneConfig *config.ZoneConfig) ([]*endpoint.Endpoint, error) {
+	source, err := s.sourceProvider(ctx, zoneConfig)
+	if err != nil {
+		return nil, err
+	}
+	_, desired, err := s.listRecords(ctx, source, zoneConfig.Source.ProviderConfig.String(), zoneConfig, nil)
+	return desired, err
+}
 
-	records, err := p.Records(ctx)
+// sourceProvider creates the provider of the source of a zone
+func (s *Synchronizer) sourceProvider(ctx context.Context, zoneConfig *config.ZoneConfig) (provider.Provider, error) {
+	sourceName := zoneConfig.Source.ProviderConfig.String()
+	source, err := providers.GetProvider(ctx, zoneConfig.Source.ProviderConfig, zoneConfig.Source.DomainFilter, zoneConfig.Source.RecordFilter, s.config.Sync.DryRun)
 	if err != nil {
-		return nil, errors.Wrapf(err, "failed to fetch records from provider %s for zone %s", p, zone.Name)
+		providerErrors.WithLabelValues(zoneConfig.Name, sourceName, "init").Inc()
+		return nil, errors.Wrapf(err, "failed to get source provider for %s", sourceName)
 	}
+	return s.resilient(s.rateLimited(source, zoneConfig.Source.ProviderConfig), zoneConfig.Source.ProviderConfig, zoneConfig.Name), nil
+}
 
-	filtered := s.filterRecords(records, zone.RecordFilter)
+// connectedTarget is a target provider of a zone along with its current records
+type connectedTarget struct {
+	name     string
+	provider provider.Provider
 
-	log.Printf("Fetched %d records, filtered: %d from source provider for zone %s", len(records), len(filtered), zone.Name)
+	// records holds all records of the target, current those matching the zone and target record filters
+	records []*endpoint.Endpoint
+	current []*endpoint.Endpoint
 
-	transformed := s.transformRecords(filtered, zone)
-	return transformed, nil
+	// domainFilter limits the records managed by the plan, nil when the target has no domain filter
+	domainFilter endpoint.MatchAllDomainFilters
 
+	// ownerID is the owner of the records managed on the target, empty without a TXT registry
+	ownerID string
 }
 
-// filterRecords filters records based on the configured filter
-func (s *Synchronizer) filterRecords(records []*endpoint.Endpoint, filter config.RecordFilterConfig) []*endpoint.Endpoint {
-	var filtered []*endpoint.Endpoint
-
-	for _, record := range records {
-		// Check if record type should be included
-		if len(filter.IncludeTypes) > 0 {
-			included := false
-			for _, includeType := range filter.IncludeTypes {
-				if record.RecordType == includeType {
-					included = true
-					break
-				}
-			}
-			if !included {
-				continue
-			}
-		}
+// connectTarget creates the provider of the target at index of a zone and fetches its current records
+func (s *Synchronizer) connectTarget(ctx context.Context, zoneConfig *config.ZoneConfig, index int) (*connectedTarget, error) {
+	targetConfig := zoneConfig.Targets[index]
+	target := &connectedTarget{name: targetConfig.ProviderConfig.String()}
 
-		// Check if record type should be excluded
-		excluded := false
-		for _, excludeType := range filter.ExcludeTypes {
-			if record.RecordType == excludeType {
-				excluded = true
-				break
-			}
-		}
-		if excluded {
-			continue
+	p, domainFilter, err := s.targetProvider(ctx, zoneConfig, index)
+	if err != nil {
+		return nil, err
+	}
+	if domainFilter != nil {
+		target.domainFilter = endpoint.MatchAllDomainFilters{domainFilter}
+	}
+
+	if targetConfig.Registry != nil {
+		p, err = providers.NewTXTRegistry(p, *targetConfig.Registry, managedRecordTypes(zoneConfig, targetConfig))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to create TXT registry for target %s", target.name)
 		}
+		target.ownerID = targetConfig.Registry.OwnerID
+	}
+	target.provider = p
 
-		// Check if record name should be included
-		if len(filter.IncludeNames) > 0 {
-			included := false
-			for _, includeName := range filter.IncludeNames {
-				if matchesPattern(record.DNSName, includeName) {
-					included = true
-					break
-				}
-			}
-			if !included {
-				continue
-			}
+	target.records, target.current, err = s.listRecords(ctx, p, target.name, zoneConfig, &targetConfig)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to get current records from target provider for %s", target.name)
+	}
+	if targetConfig.RecordFilter != nil {
+		if target.current, err = s.filterRecords(target.current, *targetConfig.RecordFilter); err != nil {
+			return nil, err
 		}
+	}
+	return target, nil
+}
 
-		// Check if record name should be excluded
-		excluded = false
-		for _, excludeName := range filter.ExcludeNames {
-			if matchesPattern(record.DNSName, excludeName) {
-				excluded = true
-				break
-			}
+// targetProvider creates the provider of the target at index of a zone, along with the domain filter of the
+// target, nil when it has none
+func (s *Synchronizer) targetProvider(ctx context.Context, zoneConfig *config.ZoneConfig, index int) (provider.Provider, *endpoint.DomainFilter, error) {
+	targetConfig := zoneConfig.Targets[index]
+	name := targetConfig.ProviderConfig.String()
+
+	var targetFilter *endpoint.DomainFilter
+	domainFilter, zoneIDFilter := zoneConfig.Source.DomainFilter, zoneConfig.Source.RecordFilter
+	if targetConfig.DomainFilter != nil {
+		var err error
+		if domainFilter, err = targetConfig.DomainFilter.Filter(); err != nil {
+			return nil, nil, errors.Wrapf(err, "invalid domain filter for target %s", name)
 		}
-		if excluded {
-			continue
+		if len(targetConfig.DomainFilter.ZoneIDFilter) > 0 {
+			zoneIDFilter = provider.NewZoneIDFilter(targetConfig.DomainFilter.ZoneIDFilter)
 		}
-
-		filtered = append(filtered, record)
+		targetFilter = &domainFilter
 	}
 
-	return filtered
+	p, err := providers.GetProvider(ctx, targetConfig.ProviderConfig, domainFilter, zoneIDFilter, s.config.Sync.DryRun)
+	if err != nil {
+		providerErrors.WithLabelValues(zoneConfig.Name, name, "init").Inc()
+		return nil, nil, errors.Wrapf(err, "failed to get target provider for %s", name)
+	}
+	return s.resilient(s.rateLimited(p, targetConfig.ProviderConfig), targetConfig.ProviderConfig, targetZoneName(zoneConfig, targetConfig)), targetFilter, nil
 }
 
-// transformRecords applies transformations to records
-func (s *Synchronizer) transformRecords(records []*endpoint.Endpoint, _ config.ZoneConfig) []*endpoint.Endpoint {
-	var transformed []*endpoint.Endpoint
+// planTarget calculates the changes needed to publish the desired records of a zone on a connected target,
+// the returned plan holds the desired records after the target transformations and filters
+func (s *Synchronizer) planTarget(zoneConfig *config.ZoneConfig, targetConfig config.TargetConfig, target *connectedTarget, desired []*endpoint.Endpoint) (*plan.Plan, error) {
+	policy, err := s.policyFor(zoneConfig, targetConfig)
+	if err != nil {
+		return nil, err
+	}
 
-	for _, record := range records {
-		// Apply TTL override if configured
-		if s.config.Sync.RecordTTL > 0 {
-			record.RecordTTL = endpoint.TTL(s.config.Sync.RecordTTL)
+	desired, err = s.transformForTarget(desired, zoneConfig, targetConfig)
+	if err != nil {
+		return nil, err
+	}
+	if targetConfig.RecordFilter != nil {
+		if desired, err = s.filterRecords(desired, *targetConfig.RecordFilter); err != nil {
+			return nil, err
 		}
+	}
 
-		// Apply zone name transformations if needed
-		// This could include mapping source zone names to target zone names
+	return Calculate(&plan.Plan{
+		Desired:        desired,
+		Current:        target.current,
+		DomainFilter:   target.domainFilter,
+		ManagedRecords: managedRecordTypes(zoneConfig, targetConfig),
+		Policies:       []plan.Policy{policy},
+		OwnerID:        target.ownerID,
+	}), nil
+}
 
-		transformed = append(transformed, record)
+// policyFor resolves the plan policy of a target, falling back to the zone policy and then to sync.
+// Policies that delete records are downgraded to upsert-only when orphaned records must be kept.
+func (s *Synchronizer) policyFor(zoneConfig *config.ZoneConfig, targetConfig config.TargetConfig) (plan.Policy, error) {
+	name := targetConfig.Policy
+	if name == "" {
+		name = zoneConfig.Policy
+	}
+	if name == "" {
+		name = "sync"
 	}
 
-	return transformed
+	policy, ok := plan.Policies[name]
+	if !ok {
+		return nil, errors.Errorf("unknown policy %q for target %s of zone %s", name, targetConfig.ProviderConfig.String(), zoneConfig.Name)
+	}
+	if name == "sync" && !s.config.Sync.DeleteOrphanedRecords() {
+		return plan.Policies["upsert-only"], nil
+	}
+	return policy, nil
 }
 
-// matchesPattern checks if a name matches a pattern (supports basic wildcards)
-func matchesPattern(name, pattern string) bool {
-	// Simple pattern matching - could be enhanced with regex or glob patterns
-	if pattern == "*" {
-		return true
+// listRecords fetches the records of a zone from the named provider, the source or the given target,
+// returning all records along with the records matching the zone record filter
+func (s *Synchronizer) listRecords(ctx context.Context, p provider.Provider, name string, zone *config.ZoneConfig, targetConfig *config.TargetConfig) (records, filtered []*endpoint.Endpoint, err error) {
+	records, err = p.Records(ctx)
+	if err != nil {
+		providerErrors.WithLabelValues(zone.Name, name, "records").Inc()
+		return nil, nil, errors.Wrapf(err, "failed to fetch records from provider %s for zone %s", name, zone.Name)
 	}
+	recordsFetched.WithLabelValues(zone.Name, name).Set(float64(len(records)))
 
-	// For now, just do exact matching
-	return name == pattern
-}
+	if targetConfig != nil {
+		filtered, err = s.filterTargetRecords(records, zone, *targetConfig)
+	} else {
+		filtered, err = s.filterRecords(records, zone.RecordFilter)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	log.WithFields(log.Fields{"zone": zone.Name, "provider": name, "records": len(records), "filtered": len(filtered)}).Info("Fetched records")
 
-// ZoneStatus represents the sync status of a zone
-type ZoneStatus struct {
-	Name         string         `json:"name"`
-	LastSync     time.Time      `json:"last_sync"`
-	LastError    string         `json:"last_error,omitempty"`
-	RecordCount  int            `json:"record_count"`
-	TargetStatus []TargetStatus `json:"target_status"`
+	return records, filtered, nil
 }
 
-// TargetStatus represents the sync status for a specific target
-type TargetStatus struct {
-	Provider    string    `json:"provider"`
-	ZoneID      string    `json:"zone_id"`
-	LastSync    time.Time `json:"last_sync"`
-	LastError   string    `json:"last_error,omitempty"`
-	RecordCount int       `json:"record_count"`
+// Ready returns true once all zones have been synced successfully at least once
+func (s *Synchronizer) Ready() bool {
+	return s.ready.Load()
 }
 
 // GetStatus returns the current sync status
 func (s *Synchronizer) GetStatus() []ZoneStatus {
-	// This would be implemented to track and return sync status
-	// For now, return empty slice
-	return []ZoneStatus{}
+	return s.status.list()
 }
@@ -4,14 +4,19 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
 	"github.com/flanksource/dns-sync/config"
+	"github.com/flanksource/dns-sync/journal"
 
 	_ "embed"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/time/rate"
 )
 
 //go:embed testdata/zones.bind
@@ -68,3 +73,393 @@ func test(t *testing.T, cfg config.Config, created, updated, deleted int) {
 	assert.Equal(t, updated, len(change.UpdateNew))
 	assert.Equal(t, deleted, len(change.Delete))
 }
+
+func TestGetStatus(t *testing.T) {
+	target, _ := os.CreateTemp("", "target.bind")
+	source, _ := os.CreateTemp("", "zones.bind")
+	_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+	cfg := config.Config{
+		Zones: []*config.ZoneConfig{
+			{
+				Name: "example.com",
+				Source: config.SourceConfig{
+					ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+				},
+				Targets: []config.TargetConfig{
+					{ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: "/nonexistent/target.bind"}}},
+					{ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}}},
+				},
+			},
+		},
+	}
+
+	s := NewSynchronizer(cfg)
+	changes, err := s.Once(context.Background())
+	assert.Error(t, err)
+	assert.Equal(t, 11, len(changes["example.com"][cfg.Zones[0].Targets[1]].Create))
+
+	status := s.GetStatus()
+	assert.Len(t, status, 1)
+	assert.Equal(t, "example.com", status[0].Name)
+	assert.Contains(t, status[0].LastError, "failed to sync 1 of 2 targets")
+	assert.True(t, status[0].LastSuccess.IsZero())
+	assert.Len(t, status[0].TargetStatus, 2)
+
+	failed, synced := status[0].TargetStatus[0], status[0].TargetStatus[1]
+	assert.Contains(t, failed.LastError, "/nonexistent/target.bind")
+	assert.True(t, failed.LastSuccess.IsZero())
+	assert.Empty(t, synced.LastError)
+	assert.Equal(t, synced.LastSync, synced.LastSuccess)
+	assert.Equal(t, 11, synced.Creates)
+}
+
+func TestPolicies(t *testing.T) {
+	disabled := false
+	tests := []struct {
+		name           string
+		zonePolicy     string
+		targetPolicy   string
+		deleteOrphaned *bool
+		deleted        int
+		err            string
+	}{
+		{name: "default", deleted: 1},
+		{name: "zone upsert-only", zonePolicy: "upsert-only", deleted: 0},
+		{name: "target overrides zone", zonePolicy: "upsert-only", targetPolicy: "sync", deleted: 1},
+		{name: "create-only", targetPolicy: "create-only", deleted: 0},
+		{name: "delete_orphaned disabled", targetPolicy: "sync", deleteOrphaned: &disabled, deleted: 0},
+		{name: "unknown", targetPolicy: "mirror", err: `unknown policy "mirror"`},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			source, _ := os.CreateTemp("", "zones.bind")
+			target, _ := os.CreateTemp("", "target.bind")
+			_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+			_ = os.WriteFile(target.Name(), []byte("orphan.example.com. 300 IN A 10.0.0.1\n"), 0600)
+
+			cfg := config.Config{
+				Sync: config.SyncConfig{DeleteOrphaned: tc.deleteOrphaned},
+				Zones: []*config.ZoneConfig{
+					{
+						Name:   "example.com",
+						Policy: tc.zonePolicy,
+						Source: config.SourceConfig{
+							ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+						},
+						Targets: []config.TargetConfig{
+							{
+								ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}},
+								Policy:         tc.targetPolicy,
+							},
+						},
+					},
+				},
+			}
+
+			s := NewSynchronizer(cfg)
+			changes, err := s.Once(context.Background())
+			if tc.err != "" {
+				assert.Error(t, err)
+				assert.Contains(t, s.GetStatus()[0].TargetStatus[0].LastError, tc.err)
+				return
+			}
+			assert.NoError(t, err)
+			change := changes["example.com"][cfg.Zones[0].Targets[0]]
+			assert.Equal(t, 11, len(change.Create))
+			assert.Equal(t, tc.deleted, len(change.Delete))
+		})
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	for name, key := range map[string]string{"plain": "", "encrypted": "0123456789abcdef0123456789abcdef"} {
+		t.Run(name, func(t *testing.T) {
+			source, _ := os.CreateTemp("", "zones.bind")
+			target, _ := os.CreateTemp("", "target.bind")
+			_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+			_ = os.WriteFile(target.Name(), []byte("manual.example.com. 300 IN A 10.0.0.1\n"), 0600)
+
+			cfg := config.Config{
+				Zones: []*config.ZoneConfig{
+					{
+						Name: "example.com",
+						Source: config.SourceConfig{
+							ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+						},
+						Targets: []config.TargetConfig{
+							{
+								ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}},
+								Registry:       &config.RegistryConfig{OwnerID: "dns-sync", EncryptionKey: key},
+							},
+						},
+					},
+				},
+			}
+
+			// Records without an owner are left alone
+			test(t, cfg, 11, 0, 0)
+			// Ownership records are not reported as changes
+			test(t, cfg, 0, 0, 0)
+
+			// Owned records that are removed from the source are deleted
+			zone := strings.NewReplacer("www       IN  A      192.0.2.81\n", "", "www       IN  A      192.0.2.82\n", "").Replace(sampleZone)
+			_ = os.WriteFile(source.Name(), []byte(zone), 0600)
+			test(t, cfg, 0, 0, 2)
+
+			records, _ := os.ReadFile(target.Name())
+			assert.Contains(t, string(records), "manual.example.com.")
+		})
+	}
+}
+
+func TestTargetFilters(t *testing.T) {
+	source, _ := os.CreateTemp("", "zones.bind")
+	public, _ := os.CreateTemp("", "public.bind")
+	internal, _ := os.CreateTemp("", "internal.bind")
+	_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+	_ = os.WriteFile(public.Name(), []byte("_sip._tcp.example.com. 300 IN SRV 0 0 5060 sip.example.com.\n"), 0600)
+	_ = os.WriteFile(internal.Name(), []byte("api.example.com. 300 IN AAAA 2001:db8::1\nold.example.com. 300 IN A 10.0.0.1\n"), 0600)
+
+	cfg := config.Config{
+		Zones: []*config.ZoneConfig{
+			{
+				Name: "example.com",
+				Source: config.SourceConfig{
+					ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+				},
+				Targets: []config.TargetConfig{
+					{
+						ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: public.Name()}},
+						RecordFilter:   &config.RecordFilterConfig{IncludeTypes: []string{"A", "AAAA", "CNAME"}},
+					},
+					{
+						ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: internal.Name()}},
+						DomainFilter:   &config.DomainFilterConfig{ExcludeDomains: []string{"api.example.com"}},
+					},
+				},
+			},
+		},
+	}
+
+	s := NewSynchronizer(cfg)
+	changes, err := s.Once(context.Background())
+	assert.NoError(t, err)
+
+	// Only address records are published, the unmanaged SRV record is kept
+	change := changes["example.com"][cfg.Zones[0].Targets[0]]
+	assert.Equal(t, 5, len(change.Create))
+	assert.Equal(t, 0, len(change.Delete))
+
+	// Records outside of the domain filter are neither created nor deleted
+	change = changes["example.com"][cfg.Zones[0].Targets[1]]
+	assert.Equal(t, 9, len(change.Create))
+	assert.Equal(t, 1, len(change.Delete))
+	assert.Equal(t, "old.example.com", change.Delete[0].DNSName)
+}
+
+func TestLowercaseTypes(t *testing.T) {
+	source, _ := os.CreateTemp("", "zones.bind")
+	target, _ := os.CreateTemp("", "target.bind")
+	_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+	_ = os.WriteFile(target.Name(), []byte("_sip._tcp.example.com. 300 IN SRV 0 0 5060 sip.example.com.\nold.example.com. 300 IN A 10.0.0.1\n"), 0600)
+
+	cfg := config.Config{
+		Zones: []*config.ZoneConfig{
+			{
+				Name:         "example.com",
+				RecordFilter: config.RecordFilterConfig{IncludeTypes: []string{"a", "Aaaa", "srv"}},
+				Source: config.SourceConfig{
+					ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+				},
+				Targets: []config.TargetConfig{
+					{
+						ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}},
+						RecordFilter:   &config.RecordFilterConfig{ExcludeTypes: []string{"srv"}},
+					},
+				},
+			},
+		},
+	}
+
+	// Types match regardless of case: the address records are synced and the excluded SRV record is kept
+	test(t, cfg, 5, 0, 1)
+	test(t, cfg, 0, 0, 0)
+
+	records, _ := os.ReadFile(target.Name())
+	assert.Contains(t, string(records), "_sip._tcp.example.com.")
+}
+
+func TestRenamedTargetFilters(t *testing.T) {
+	source, _ := os.CreateTemp("", "zones.bind")
+	target, _ := os.CreateTemp("", "target.bind")
+	_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+	_ = os.WriteFile(target.Name(), []byte("old.dr.example.net. 300 IN A 10.0.0.1\nmanual.dr.example.net. 300 IN A 10.0.0.2\n"), 0600)
+
+	cfg := config.Config{
+		Zones: []*config.ZoneConfig{
+			{
+				Name:         "example.com",
+				RecordFilter: config.RecordFilterConfig{IncludeNames: []string{"www.example.com", "old.*"}},
+				Source: config.SourceConfig{
+					ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+				},
+				Targets: []config.TargetConfig{
+					{
+						ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}},
+						Rename:         &config.RenameConfig{To: "dr.example.net"},
+					},
+				},
+			},
+		},
+	}
+
+	// The name patterns of the zone match the records of the target under their source names: the orphan
+	// is deleted, the record outside the filter is kept
+	s := NewSynchronizer(cfg)
+	changes, err := s.Once(context.Background())
+	require.NoError(t, err)
+	change := changes["example.com"][cfg.Zones[0].Targets[0]]
+	assert.Equal(t, 3, len(change.Create))
+	require.Equal(t, 1, len(change.Delete))
+	assert.Equal(t, "old.dr.example.net", change.Delete[0].DNSName)
+
+	// Once published, the renamed records are current and not created again
+	test(t, cfg, 0, 0, 0)
+}
+
+func TestSafety(t *testing.T) {
+	tests := []struct {
+		name         string
+		zoneSafety   *config.SafetyConfig
+		targetSafety *config.SafetyConfig
+		refused      string
+	}{
+		{name: "no thresholds"},
+		{name: "max deletes", zoneSafety: &config.SafetyConfig{MaxDeletes: 5}, refused: "8 deletes exceeds the maximum of 5"},
+		{name: "max delete percent", targetSafety: &config.SafetyConfig{MaxDeletePercent: 50}, refused: "deleting 72.7% of 11 records"},
+		{name: "min records", zoneSafety: &config.SafetyConfig{MinRecords: 5}, refused: "3 desired records is below the minimum of 5"},
+		{name: "target overrides zone", zoneSafety: &config.SafetyConfig{MaxDeletes: 5}, targetSafety: &config.SafetyConfig{MaxDeletes: 10}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			source, _ := os.CreateTemp("", "zones.bind")
+			target, _ := os.CreateTemp("", "target.bind")
+			_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+
+			cfg := config.Config{
+				Zones: []*config.ZoneConfig{
+					{
+						Name:   "example.com",
+						Safety: tc.zoneSafety,
+						Source: config.SourceConfig{
+							ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+						},
+						Targets: []config.TargetConfig{
+							{
+								ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}},
+								Safety:         tc.targetSafety,
+							},
+						},
+					},
+				},
+			}
+			test(t, cfg, 11, 0, 0)
+
+			// A truncated source must not remove most of the target
+			_ = os.WriteFile(source.Name(), []byte("$ORIGIN example.com.\nwww IN A 192.0.2.80\nwww IN A 192.0.2.81\nwww IN A 192.0.2.82\n"), 0600)
+			s := NewSynchronizer(cfg)
+			changes, err := s.Once(context.Background())
+			status := s.GetStatus()[0].TargetStatus[0]
+			if tc.refused == "" {
+				assert.NoError(t, err)
+				assert.Equal(t, 8, len(changes["example.com"][cfg.Zones[0].Targets[0]].Delete))
+				assert.False(t, status.Refused)
+				return
+			}
+
+			assert.Error(t, err)
+			assert.True(t, status.Refused)
+			assert.Equal(t, 8, status.Deletes)
+			assert.Contains(t, status.LastError, tc.refused)
+
+			records, _ := os.ReadFile(target.Name())
+			assert.Contains(t, string(records), "ldap1.example.com.")
+		})
+	}
+}
+
+func TestJournal(t *testing.T) {
+	source, _ := os.CreateTemp("", "zones.bind")
+	target, _ := os.CreateTemp("", "target.bind")
+	_ = os.WriteFile(source.Name(), []byte(sampleZone), 0600)
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	cfg := config.Config{
+		Journal: config.JournalConfig{Path: path},
+		Zones: []*config.ZoneConfig{
+			{
+				Name: "example.com",
+				Source: config.SourceConfig{
+					ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: source.Name()}},
+				},
+				Targets: []config.TargetConfig{
+					{ProviderConfig: config.ProviderConfig{File: &config.FileProviderConfig{Path: target.Name()}}},
+				},
+			},
+		},
+	}
+
+	test(t, cfg, 11, 0, 0)
+	// Syncs without changes are not journaled
+	test(t, cfg, 0, 0, 0)
+
+	entries, err := journal.Read(path, journal.Query{})
+	assert.NoError(t, err)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, "example.com", entries[0].Zone)
+	assert.Equal(t, cfg.Zones[0].Targets[0].ProviderConfig.String(), entries[0].Target)
+	assert.NotEmpty(t, entries[0].ConfigHash)
+	assert.Len(t, entries[0].Changes.Create, 11)
+}
+
+func TestRateLimiters(t *testing.T) {
+	file := func(path string, limit *config.RateLimitConfig) config.ProviderConfig {
+		return config.ProviderConfig{File: &config.FileProviderConfig{Path: path}, RateLimit: limit}
+	}
+	cfg := config.Config{
+		Sync: config.SyncConfig{RateLimit: config.RateLimitConfig{RequestsPerSecond: 10}},
+		Zones: []*config.ZoneConfig{
+			{
+				Name:    "example.com",
+				Source:  config.SourceConfig{ProviderConfig: file("source.bind", nil)},
+				Targets: []config.TargetConfig{{ProviderConfig: file("target.bind", nil)}},
+			},
+			{
+				Name:   "example.org",
+				Source: config.SourceConfig{ProviderConfig: file("source.bind", &config.RateLimitConfig{RequestsPerSecond: 2, Burst: 5})},
+				Targets: []config.TargetConfig{
+					{ProviderConfig: file("target.bind", nil)},
+					{ProviderConfig: file("unlimited.bind", &config.RateLimitConfig{})},
+				},
+			},
+		},
+	}
+
+	limiters := newLimiters(cfg)
+	require.Len(t, limiters, 2)
+
+	// Zones using the same account share its limiter, configured by the first provider that sets one
+	source := limiters[cfg.Zones[0].Source.Account()]
+	require.NotNil(t, source)
+	assert.Equal(t, rate.Limit(2), source.Limit())
+	assert.Equal(t, 5, source.Burst())
+
+	target := limiters[cfg.Zones[0].Targets[0].Account()]
+	require.NotNil(t, target)
+	assert.Equal(t, rate.Limit(10), target.Limit())
+	assert.Equal(t, 1, target.Burst())
+
+	assert.Nil(t, limiters[cfg.Zones[1].Targets[1].Account()])
+}
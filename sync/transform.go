@@ -0,0 +1,293 @@
+package sync
+
+import (
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/pkg/errors"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+// transformForTarget returns copies of the desired records with the transformations of a target applied,
+// leaving the records shared between targets untouched
+func (s *Synchronizer) transformForTarget(records []*endpoint.Endpoint, zoneConfig *config.ZoneConfig, targetConfig config.TargetConfig) ([]*endpoint.Endpoint, error) {
+	var rewriter *rewriter
+	if targetConfig.Rewrite != nil {
+		var err error
+		if rewriter, err = newRewriter(*targetConfig.Rewrite); err != nil {
+			return nil, errors.Wrapf(err, "invalid rewrite for target %s", targetConfig.ProviderConfig.String())
+		}
+	}
+
+	ttlPolicy := s.ttlPolicy(zoneConfig, targetConfig)
+
+	transformed := make([]*endpoint.Endpoint, 0, len(records))
+	for _, record := range records {
+		record = record.DeepCopy()
+
+		if targetConfig.Rename != nil {
+			renameRecord(record, zoneConfig.Name, *targetConfig.Rename)
+		}
+		if rewriter != nil {
+			rewriter.rewrite(record)
+		}
+		applyTTL(record, ttlPolicy)
+
+		transformed = append(transformed, record)
+	}
+	return transformed, nil
+}
+
+// ttlPolicy returns the effective TTL policy of a target: the global record_ttl, overridden by the zone
+// and then the target policy, with the minimum raised to the minimum TTL of the target provider
+func (s *Synchronizer) ttlPolicy(zoneConfig *config.ZoneConfig, targetConfig config.TargetConfig) config.TTLPolicyConfig {
+	policy := config.TTLPolicyConfig{TTL: s.config.Sync.RecordTTL, Types: map[string]uint32{}}
+
+	for _, override := range []*config.TTLPolicyConfig{zoneConfig.TTL, targetConfig.TTL} {
+		if override == nil {
+			continue
+		}
+		if override.TTL > 0 {
+			policy.TTL = override.TTL
+		}
+		if override.Min > 0 {
+			policy.Min = override.Min
+		}
+		if override.Max > 0 {
+			policy.Max = override.Max
+		}
+		for recordType, ttl := range override.Types {
+			policy.Types[strings.ToUpper(recordType)] = ttl
+		}
+	}
+
+	if minTTL := targetConfig.ProviderConfig.MinTTL(); minTTL > policy.Min {
+		policy.Min = minTTL
+	}
+	return policy
+}
+
+// applyTTL sets the TTL of a record according to the policy, records without a TTL are left to the
+// provider default
+func applyTTL(record *endpoint.Endpoint, policy config.TTLPolicyConfig) {
+	ttl := uint32(record.RecordTTL)
+	if typeTTL, ok := policy.Types[record.RecordType]; ok {
+		if typeTTL > 0 {
+			ttl = typeTTL
+		}
+	} else if policy.TTL > 0 {
+		ttl = policy.TTL
+	}
+
+	if ttl == 0 {
+		return
+	}
+	// The minimum wins over the maximum, as it is usually enforced by the provider
+	if policy.Max > 0 && ttl > policy.Max {
+		ttl = policy.Max
+	}
+	if ttl < policy.Min {
+		ttl = policy.Min
+	}
+	record.RecordTTL = endpoint.TTL(ttl)
+}
+
+// targetZoneName returns the name of the zone as published on a target
+func targetZoneName(zoneConfig *config.ZoneConfig, targetConfig config.TargetConfig) string {
+	if targetConfig.Rename == nil {
+		return zoneConfig.Name
+	}
+	return replaceSuffix(zoneConfig.Name, renameFrom(zoneConfig.Name, *targetConfig.Rename), targetConfig.Rename.To)
+}
+
+func renameFrom(zoneName string, rename config.RenameConfig) string {
+	if rename.From != "" {
+		return rename.From
+	}
+	return zoneName
+}
+
+// renameRecord moves a record from the source zone into the target zone, optionally rewriting
+// CNAME, MX and SRV targets that point into the source zone
+func renameRecord(record *endpoint.Endpoint, zoneName string, rename config.RenameConfig) {
+	from := renameFrom(zoneName, rename)
+	record.DNSName = replaceSuffix(record.DNSName, from, rename.To)
+
+	if !rename.RewriteTargets {
+		return
+	}
+
+	for i, target := range record.Targets {
+		switch record.RecordType {
+		case endpoint.RecordTypeCNAME:
+			record.Targets[i] = replaceSuffix(target, from, rename.To)
+		case endpoint.RecordTypeMX:
+			// <preference> <host>
+			record.Targets[i] = replaceField(target, 1, from, rename.To)
+		case endpoint.RecordTypeSRV:
+			// <priority> <weight> <port> <host>
+			record.Targets[i] = replaceField(target, 3, from, rename.To)
+		}
+	}
+}
+
+// replaceField replaces the zone suffix of the whitespace separated field at index
+func replaceField(value string, index int, from, to string) string {
+	fields := strings.Fields(value)
+	if len(fields) <= index {
+		return value
+	}
+	fields[index] = replaceSuffix(fields[index], from, to)
+	return strings.Join(fields, " ")
+}
+
+// replaceSuffix replaces the zone suffix from of name with to, names outside the zone are returned unchanged
+func replaceSuffix(name, from, to string) string {
+	from = normalizeName(from)
+	to = strings.TrimSuffix(to, ".")
+	trimmed := strings.TrimSuffix(name, ".")
+	lower := strings.ToLower(trimmed)
+
+	var renamed string
+	switch {
+	case lower == from:
+		renamed = to
+	case strings.HasSuffix(lower, "."+from):
+		renamed = trimmed[:len(trimmed)-len(from)] + to
+	default:
+		return name
+	}
+
+	if strings.HasSuffix(name, ".") {
+		renamed += "."
+	}
+	return renamed
+}
+
+// addressRewrite maps addresses within one network to the same host in another
+type addressRewrite struct {
+	from, to netip.Prefix
+}
+
+// suffixRewrite maps names within one domain to the same name in another
+type suffixRewrite struct {
+	from, to string
+}
+
+// rewriter rewrites record values as configured by a RewriteConfig
+type rewriter struct {
+	addresses []addressRewrite
+	suffixes  []suffixRewrite
+}
+
+func newRewriter(cfg config.RewriteConfig) (*rewriter, error) {
+	r := &rewriter{}
+
+	for from, to := range cfg.Addresses {
+		fromPrefix, err := parsePrefix(from)
+		if err != nil {
+			return nil, err
+		}
+		toPrefix, err := parsePrefix(to)
+		if err != nil {
+			return nil, err
+		}
+		if fromPrefix.Addr().Is4() != toPrefix.Addr().Is4() || fromPrefix.Bits() != toPrefix.Bits() {
+			return nil, errors.Errorf("cannot rewrite %s to %s, networks must be of the same family and size", from, to)
+		}
+		r.addresses = append(r.addresses, addressRewrite{from: fromPrefix, to: toPrefix})
+	}
+	// Most specific network first
+	sort.Slice(r.addresses, func(i, j int) bool {
+		if r.addresses[i].from.Bits() != r.addresses[j].from.Bits() {
+			return r.addresses[i].from.Bits() > r.addresses[j].from.Bits()
+		}
+		return r.addresses[i].from.String() < r.addresses[j].from.String()
+	})
+
+	for from, to := range cfg.CNAMEs {
+		if normalizeName(from) == "" {
+			return nil, errors.Errorf("invalid cname suffix %q", from)
+		}
+		r.suffixes = append(r.suffixes, suffixRewrite{from: normalizeName(from), to: to})
+	}
+	// Longest suffix first
+	sort.Slice(r.suffixes, func(i, j int) bool {
+		if len(r.suffixes[i].from) != len(r.suffixes[j].from) {
+			return len(r.suffixes[i].from) > len(r.suffixes[j].from)
+		}
+		return r.suffixes[i].from < r.suffixes[j].from
+	})
+
+	return r, nil
+}
+
+// parsePrefix parses a CIDR, or a single IP as a host prefix
+func parsePrefix(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, errors.Wrapf(err, "invalid network %q", value)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, errors.Wrapf(err, "invalid address %q", value)
+	}
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+func (r *rewriter) rewrite(record *endpoint.Endpoint) {
+	for i, target := range record.Targets {
+		switch record.RecordType {
+		case endpoint.RecordTypeA, endpoint.RecordTypeAAAA:
+			record.Targets[i] = r.rewriteAddress(target)
+		case endpoint.RecordTypeCNAME:
+			record.Targets[i] = r.rewriteName(target)
+		}
+	}
+}
+
+// rewriteAddress maps an address through the most specific matching network, keeping its host bits
+func (r *rewriter) rewriteAddress(value string) string {
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return value
+	}
+	for _, rewrite := range r.addresses {
+		if !rewrite.from.Contains(addr) {
+			continue
+		}
+		from, to := addr.AsSlice(), rewrite.to.Addr().AsSlice()
+		bits := rewrite.to.Bits()
+		for i := range to {
+			switch {
+			case bits >= 8:
+				bits -= 8
+			case bits > 0:
+				mask := byte(0xff) >> bits
+				to[i] = to[i]&^mask | from[i]&mask
+				bits = 0
+			default:
+				to[i] = from[i]
+			}
+		}
+		rewritten, _ := netip.AddrFromSlice(to)
+		return rewritten.String()
+	}
+	return value
+}
+
+// rewriteName maps a name through the longest matching domain suffix
+func (r *rewriter) rewriteName(value string) string {
+	for _, rewrite := range r.suffixes {
+		name := normalizeName(value)
+		if name == rewrite.from || strings.HasSuffix(name, "."+rewrite.from) {
+			return replaceSuffix(value, rewrite.from, rewrite.to)
+		}
+	}
+	return value
+}
@@ -0,0 +1,161 @@
+package sync
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/flanksource/dns-sync/config"
+	"github.com/stretchr/testify/assert"
+	"sigs.k8s.io/external-dns/endpoint"
+)
+
+func TestRename(t *testing.T) {
+	records := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("corp.example.com", "MX", "10 mail.corp.example.com"),
+		endpoint.NewEndpoint("www.corp.example.com", "A", "192.0.2.1"),
+		endpoint.NewEndpoint("app.Corp.Example.com.", "CNAME", "www.corp.example.com"),
+		endpoint.NewEndpoint("cdn.corp.example.com", "CNAME", "cdn.provider.net"),
+		endpoint.NewEndpoint("_ldap._tcp.corp.example.com", "SRV", "0 50 389 ldap.corp.example.com"),
+		endpoint.NewEndpoint("othercorp.example.com", "A", "192.0.2.2"),
+	}
+	zone := &config.ZoneConfig{Name: "corp.example.com"}
+
+	s := NewSynchronizer(config.Config{})
+	renamed, err := s.transformForTarget(records, zone, config.TargetConfig{
+		Rename: &config.RenameConfig{To: "dr.example.net", RewriteTargets: true},
+	})
+	assert.NoError(t, err)
+
+	var got []string
+	for _, record := range renamed {
+		got = append(got, record.DNSName+" "+record.RecordType+" "+record.Targets[0])
+	}
+	assert.Equal(t, []string{
+		"dr.example.net MX 10 mail.dr.example.net",
+		"www.dr.example.net A 192.0.2.1",
+		"app.dr.example.net CNAME www.dr.example.net",
+		"cdn.dr.example.net CNAME cdn.provider.net",
+		"_ldap._tcp.dr.example.net SRV 0 50 389 ldap.dr.example.net",
+		"othercorp.example.com A 192.0.2.2",
+	}, got)
+
+	// Shared records are left untouched for other targets
+	assert.Equal(t, "www.corp.example.com", records[1].DNSName)
+
+	renamed, _ = s.transformForTarget(records, zone, config.TargetConfig{
+		Rename: &config.RenameConfig{To: "dr.example.net"},
+	})
+	assert.Equal(t, "www.corp.example.com", renamed[2].Targets[0])
+	assert.Equal(t, "dr.example.net", targetZoneName(zone, config.TargetConfig{Rename: &config.RenameConfig{To: "dr.example.net."}}))
+}
+
+func TestRewrite(t *testing.T) {
+	records := []*endpoint.Endpoint{
+		endpoint.NewEndpoint("www.example.com", "A", "10.1.2.3", "10.2.0.9", "10.4.17.5", "192.168.0.1"),
+		endpoint.NewEndpoint("gw.example.com", "A", "10.0.0.1"),
+		endpoint.NewEndpoint("v6.example.com", "AAAA", "fd00::1:2"),
+		endpoint.NewEndpoint("app.example.com", "CNAME", "lb.internal.example.com"),
+		endpoint.NewEndpoint("db.example.com", "CNAME", "db.eu.internal.example.com"),
+		endpoint.NewEndpoint("cdn.example.com", "CNAME", "cdn.provider.net"),
+	}
+
+	s := NewSynchronizer(config.Config{})
+	rewritten, err := s.transformForTarget(records, &config.ZoneConfig{Name: "example.com"}, config.TargetConfig{
+		Rewrite: &config.RewriteConfig{
+			Addresses: map[string]string{
+				"10.0.0.0/8":   "172.16.0.0/8",
+				"10.1.0.0/16":  "198.18.0.0/16",
+				"10.4.16.0/21": "198.51.96.0/21",
+				"10.0.0.1":     "198.51.100.1",
+				"fd00::/64":    "2001:db8::/64",
+			},
+			CNAMEs: map[string]string{
+				"internal.example.com":    "public.example.com",
+				"eu.internal.example.com": "eu.example.net",
+			},
+		},
+	})
+	assert.NoError(t, err)
+
+	var got []string
+	for _, record := range rewritten {
+		got = append(got, record.DNSName+" "+record.RecordType+" "+strings.Join(record.Targets, ","))
+	}
+	assert.Equal(t, []string{
+		"www.example.com A 198.18.2.3,172.2.0.9,198.51.97.5,192.168.0.1",
+		"gw.example.com A 198.51.100.1",
+		"v6.example.com AAAA 2001:db8::1:2",
+		"app.example.com CNAME lb.public.example.com",
+		"db.example.com CNAME db.eu.example.net",
+		"cdn.example.com CNAME cdn.provider.net",
+	}, got)
+	assert.Equal(t, "10.1.2.3", records[0].Targets[0])
+
+	for _, addresses := range []map[string]string{
+		{"10.0.0.0/8": "203.0.113.0/24"},
+		{"10.0.0.1": "2001:db8::1"},
+		{"not-an-ip": "10.0.0.1"},
+	} {
+		_, err := s.transformForTarget(records, &config.ZoneConfig{Name: "example.com"}, config.TargetConfig{
+			Rewrite: &config.RewriteConfig{Addresses: addresses},
+		})
+		assert.Error(t, err, addresses)
+	}
+}
+
+func TestTTLPolicy(t *testing.T) {
+	tests := []struct {
+		name       string
+		recordTTL  uint32
+		zoneTTL    *config.TTLPolicyConfig
+		targetTTL  *config.TTLPolicyConfig
+		provider   config.ProviderConfig
+		a, mx, txt endpoint.TTL
+	}{
+		{name: "keep source", a: 60, mx: 3600, txt: 0},
+		{name: "global fixed", recordTTL: 300, a: 300, mx: 300, txt: 300},
+		{name: "zone overrides global", recordTTL: 300, zoneTTL: &config.TTLPolicyConfig{TTL: 900}, a: 900, mx: 900, txt: 900},
+		{name: "clamped", zoneTTL: &config.TTLPolicyConfig{Min: 120, Max: 1800}, a: 120, mx: 1800, txt: 0},
+		{
+			name:      "per type",
+			zoneTTL:   &config.TTLPolicyConfig{TTL: 300, Types: map[string]uint32{"MX": 86400, "TXT": 0}},
+			targetTTL: &config.TTLPolicyConfig{Types: map[string]uint32{"mx": 7200}},
+			a:         300, mx: 7200, txt: 0,
+		},
+		{
+			name:      "target overrides zone",
+			zoneTTL:   &config.TTLPolicyConfig{TTL: 300, Max: 600},
+			targetTTL: &config.TTLPolicyConfig{Max: 200},
+			a:         200, mx: 200, txt: 200,
+		},
+		{
+			name:     "provider minimum",
+			zoneTTL:  &config.TTLPolicyConfig{Max: 30},
+			provider: config.ProviderConfig{NS1: &config.NS1ProviderConfig{MinTTLSeconds: 60}},
+			a:        60, mx: 60, txt: 0,
+		},
+		{
+			name:     "rfc2136 minimum",
+			provider: config.ProviderConfig{RFC2136: &config.RFC2136ProviderConfig{MinTTL: 5 * time.Minute}},
+			a:        300, mx: 3600, txt: 0,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			records := []*endpoint.Endpoint{
+				endpoint.NewEndpointWithTTL("www.example.com", "A", 60, "192.0.2.1"),
+				endpoint.NewEndpointWithTTL("example.com", "MX", 3600, "10 mail.example.com"),
+				endpoint.NewEndpoint("example.com", "TXT", "v=spf1 -all"),
+			}
+			s := NewSynchronizer(config.Config{Sync: config.SyncConfig{RecordTTL: tc.recordTTL}})
+			transformed, err := s.transformForTarget(records, &config.ZoneConfig{Name: "example.com", TTL: tc.zoneTTL},
+				config.TargetConfig{ProviderConfig: tc.provider, TTL: tc.targetTTL})
+			assert.NoError(t, err)
+			assert.Equal(t, []endpoint.TTL{tc.a, tc.mx, tc.txt},
+				[]endpoint.TTL{transformed[0].RecordTTL, transformed[1].RecordTTL, transformed[2].RecordTTL})
+			assert.Equal(t, endpoint.TTL(60), records[0].RecordTTL)
+		})
+	}
+}